@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/JoshPattman/jpf"
+)
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 1024
+)
+
+type AnthropicModelBuilder struct {
+	model *anthropicModel
+}
+
+// BuildAnthropicModel creates a model that talks to the Anthropic Messages API.
+func BuildAnthropicModel(key, modelName string) *AnthropicModelBuilder {
+	return &AnthropicModelBuilder{
+		model: &anthropicModel{
+			key:       key,
+			model:     modelName,
+			url:       "https://api.anthropic.com",
+			maxOutput: anthropicDefaultMaxTokens,
+		},
+	}
+}
+
+func (b *AnthropicModelBuilder) Validate() (jpf.Model, error) {
+	if b.model.model == "" {
+		return nil, fmt.Errorf("anthropic backend requires a model name")
+	}
+	if b.model.maxOutput <= 0 {
+		return nil, fmt.Errorf("anthropic backend requires max tokens to be set")
+	}
+	return b.model, nil
+}
+
+// WithURL sets the base URL of the API, without the /v1 suffix.
+func (b *AnthropicModelBuilder) WithURL(url string) *AnthropicModelBuilder {
+	b.model.url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), "/v1")
+	return b
+}
+
+func (b *AnthropicModelBuilder) WithTemperature(temp float64) *AnthropicModelBuilder {
+	b.model.temperature = &temp
+	return b
+}
+
+// WithTokens sets the token limits. The output limit is sent as max_tokens, which the API requires.
+func (b *AnthropicModelBuilder) WithTokens(input, output int) *AnthropicModelBuilder {
+	b.model.maxInput = input
+	b.model.maxOutput = output
+	return b
+}
+
+// WithStream makes the model stream its responses, writing each piece of text to w as it arrives.
+func (b *AnthropicModelBuilder) WithStream(w io.Writer) *AnthropicModelBuilder {
+	b.model.stream = w
+	return b
+}
+
+type anthropicModel struct {
+	key         string
+	model       string
+	url         string
+	temperature *float64
+	maxInput    int
+	maxOutput   int
+	stream      io.Writer
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropicErrorBody struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (m *anthropicModel) Tokens() (int, int) {
+	return m.maxInput, m.maxOutput
+}
+
+// anthropicMessages splits out the system prompt, which the API takes separately from the conversation.
+// Consecutive messages with the same role are merged, as the API expects user and assistant turns to alternate.
+func anthropicMessages(msgs []jpf.Message) (string, []map[string]string, error) {
+	system := []string{}
+	out := []map[string]string{}
+	for _, msg := range msgs {
+		if msg.Role == jpf.SystemRole {
+			system = append(system, msg.Content)
+			continue
+		}
+		role, err := roleName(msg.Role)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(out) > 0 && out[len(out)-1]["role"] == role {
+			out[len(out)-1]["content"] += "\n\n" + msg.Content
+			continue
+		}
+		out = append(out, map[string]string{"role": role, "content": msg.Content})
+	}
+	if len(out) == 0 {
+		return "", nil, fmt.Errorf("anthropic backend requires at least one user message")
+	}
+	return strings.Join(system, "\n\n"), out, nil
+}
+
+func (m *anthropicModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	system, chatMsgs, err := anthropicMessages(msgs)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, err
+	}
+	body := map[string]any{
+		"model":      m.model,
+		"messages":   chatMsgs,
+		"max_tokens": m.maxOutput,
+		"stream":     m.stream != nil,
+	}
+	if system != "" {
+		body["system"] = system
+	}
+	if m.temperature != nil {
+		body["temperature"] = *m.temperature
+	}
+	headers := map[string]string{
+		"x-api-key":         m.key,
+		"anthropic-version": anthropicVersion,
+	}
+	resp, err := doJSON(http.DefaultClient, "POST", m.url+"/v1/messages", headers, body)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, anthropicError(err)
+	}
+	defer resp.Body.Close()
+
+	var content string
+	var usage jpf.Usage
+	if m.stream == nil {
+		content, usage, err = readAnthropicResponse(resp.Body)
+	} else {
+		content, usage, err = m.readAnthropicStream(resp.Body)
+		io.WriteString(m.stream, "\n")
+	}
+	if err != nil {
+		return nil, jpf.Message{}, usage, err
+	}
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: content}, usage, nil
+}
+
+func readAnthropicResponse(r io.Reader) (string, jpf.Usage, error) {
+	var respTyped struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Usage anthropicUsage `json:"usage"`
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", jpf.Usage{}, err
+	}
+	if err := json.Unmarshal(data, &respTyped); err != nil {
+		return "", jpf.Usage{}, fmt.Errorf("failed to parse response: %s", string(data))
+	}
+	usage := jpf.Usage(respTyped.Usage)
+	content := strings.Builder{}
+	for _, block := range respTyped.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return "", usage, fmt.Errorf("response contained no text: %s", string(data))
+	}
+	return content.String(), usage, nil
+}
+
+// readAnthropicStream reads the server-sent events of a streamed response.
+// Input tokens are reported when the message starts, and output tokens when it finishes.
+func (m *anthropicModel) readAnthropicStream(r io.Reader) (string, jpf.Usage, error) {
+	content := strings.Builder{}
+	usage := jpf.Usage{}
+	err := readLines(r, func(line string) (bool, error) {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			return true, nil
+		}
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage anthropicUsage `json:"usage"`
+			} `json:"message"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage anthropicUsage     `json:"usage"`
+			Error anthropicErrorBody `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return false, fmt.Errorf("failed to parse stream event: %s", data)
+		}
+		switch event.Type {
+		case "message_start":
+			usage.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				content.WriteString(event.Delta.Text)
+				io.WriteString(m.stream, event.Delta.Text)
+			}
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return false, nil
+		case "error":
+			return false, &APIError{Kind: event.Error.Type, Message: describeAnthropicError(event.Error)}
+		}
+		return true, nil
+	})
+	return content.String(), usage, err
+}
+
+// anthropicError fills in the kind and message of an API error from the JSON error body the API sends.
+func anthropicError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	var body struct {
+		Error anthropicErrorBody `json:"error"`
+	}
+	if json.Unmarshal([]byte(apiErr.Message), &body) != nil || body.Error.Type == "" {
+		return err
+	}
+	return &APIError{
+		Status:  apiErr.Status,
+		Kind:    body.Error.Type,
+		Message: describeAnthropicError(body.Error),
+	}
+}
+
+func describeAnthropicError(e anthropicErrorBody) string {
+	switch e.Type {
+	case "authentication_error":
+		return "the API key was rejected (" + e.Message + ")"
+	case "permission_error":
+		return "the API key does not have permission to do this (" + e.Message + ")"
+	case "rate_limit_error":
+		return "rate limited, try again later (" + e.Message + ")"
+	case "overloaded_error":
+		return "the API is overloaded, try again later (" + e.Message + ")"
+	default:
+		return e.Message
+	}
+}
@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/jpf"
+)
+
+func TestAnthropicRequest(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("expected a request to /v1/messages, got %s", r.URL.Path)
+		}
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		io.WriteString(w, `{"content":[{"type":"text","text":"hello"},{"type":"tool_use"},{"type":"text","text":" there"}],"usage":{"input_tokens":12,"output_tokens":3}}`)
+	}))
+	defer server.Close()
+
+	model, err := BuildAnthropicModel("key", "claude").WithURL(server.URL + "/v1/").Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp, usage, err := model.Respond([]jpf.Message{
+		{Role: jpf.SystemRole, Content: "be nice"},
+		{Role: jpf.UserRole, Content: "hi"},
+		{Role: jpf.UserRole, Content: "again"},
+		{Role: jpf.AssistantRole, Content: "ok"},
+		{Role: jpf.UserRole, Content: "bye"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hello there" || resp.Role != jpf.AssistantRole {
+		t.Errorf("expected the text blocks to be joined, got %+v", resp)
+	}
+	if usage.InputTokens != 12 || usage.OutputTokens != 3 {
+		t.Errorf("expected usage 12 in, 3 out, got %+v", usage)
+	}
+	if got := header.Get("x-api-key"); got != "key" {
+		t.Errorf("expected the key in x-api-key, got %q", got)
+	}
+	if got := header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("expected anthropic-version %s, got %q", anthropicVersion, got)
+	}
+	if body["system"] != "be nice" {
+		t.Errorf("expected the system prompt in the system field, got %v", body["system"])
+	}
+	if body["max_tokens"] != float64(anthropicDefaultMaxTokens) {
+		t.Errorf("expected max_tokens to default to %d, got %v", anthropicDefaultMaxTokens, body["max_tokens"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Errorf("expected no temperature when none was set")
+	}
+	msgs, _ := json.Marshal(body["messages"])
+	want := `[{"content":"hi\n\nagain","role":"user"},{"content":"ok","role":"assistant"},{"content":"bye","role":"user"}]`
+	if string(msgs) != want {
+		t.Errorf("expected messages %s, got %s", want, msgs)
+	}
+}
+
+func TestAnthropicErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  int
+		body    string
+		kind    string
+		message string
+	}{
+		{
+			name:    "authentication",
+			status:  401,
+			body:    `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`,
+			kind:    "authentication_error",
+			message: "the API key was rejected (invalid x-api-key)",
+		},
+		{
+			name:    "rate limit",
+			status:  429,
+			body:    `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			kind:    "rate_limit_error",
+			message: "rate limited, try again later (slow down)",
+		},
+		{
+			name:    "unknown kind",
+			status:  400,
+			body:    `{"type":"error","error":{"type":"invalid_request_error","message":"messages: required"}}`,
+			kind:    "invalid_request_error",
+			message: "messages: required",
+		},
+		{
+			name:    "not json",
+			status:  502,
+			body:    "bad gateway",
+			message: "bad gateway",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+				io.WriteString(w, c.body)
+			}))
+			defer server.Close()
+			model, err := BuildAnthropicModel("key", "claude").WithURL(server.URL).Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, _, err = model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected an *APIError, got %v", err)
+			}
+			if apiErr.Status != c.status || apiErr.Kind != c.kind || apiErr.Message != c.message {
+				t.Errorf("expected %d (%s) %q, got %d (%s) %q", c.status, c.kind, c.message, apiErr.Status, apiErr.Kind, apiErr.Message)
+			}
+		})
+	}
+}
+
+func TestReadAnthropicResponseNoText(t *testing.T) {
+	_, usage, err := readAnthropicResponse(strings.NewReader(`{"content":[{"type":"tool_use"}],"usage":{"input_tokens":5,"output_tokens":1}}`))
+	if err == nil || !strings.Contains(err.Error(), "no text") {
+		t.Errorf("expected an error about the missing text, got %v", err)
+	}
+	if usage.InputTokens != 5 || usage.OutputTokens != 1 {
+		t.Errorf("expected the usage to be kept, got %+v", usage)
+	}
+	if _, _, err := readAnthropicResponse(strings.NewReader("not json")); err == nil {
+		t.Errorf("expected an error for a response that is not JSON")
+	}
+}
+
+func TestAnthropicNoUserMessage(t *testing.T) {
+	if _, _, err := anthropicMessages([]jpf.Message{{Role: jpf.SystemRole, Content: "alone"}}); err == nil {
+		t.Errorf("expected an error for a conversation with only a system prompt")
+	}
+}
@@ -0,0 +1,242 @@
+package backend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// HealthChecker is implemented by models that can check that their server is up and ready.
+type HealthChecker interface {
+	Health() error
+}
+
+// ModelLister is implemented by models whose server can list the models it is able to serve.
+type ModelLister interface {
+	ListModels() ([]string, error)
+}
+
+// Build creates the model described by profile, wrapped so that it respects the profile's limits.
+// If stream is non-nil and the profile asks for streaming, responses are written to it as they arrive.
+func Build(profile Profile, stream io.Writer) (jpf.Model, error) {
+	if !profile.Stream {
+		stream = nil
+	}
+	model, err := BuildRaw(profile, stream)
+	if err != nil {
+		return nil, err
+	}
+	return WithLimits(model, profile.Price, profile.Limits), nil
+}
+
+// BuildRaw creates the raw model for the provider named in profile, without any limits.
+// If stream is non-nil, responses are streamed to it (only some providers support this).
+// The profile's BaseURL is the base of the API for every provider, and the path of each request is added to it.
+func BuildRaw(profile Profile, stream io.Writer) (jpf.Model, error) {
+	switch profile.Provider {
+	case "openai":
+		if stream != nil {
+			return nil, fmt.Errorf("the openai provider does not support streaming")
+		}
+		builder := jpf.BuildOpenAIModel(os.Getenv(profile.KeyEnv), profile.Model, false)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(strings.TrimSuffix(profile.BaseURL, "/") + "/chat/completions")
+		}
+		if profile.Temperature != nil {
+			builder = builder.WithTemperature(*profile.Temperature)
+		}
+		if profile.MaxTokens > 0 {
+			builder = builder.WithTokens(0, profile.MaxTokens)
+		}
+		return builder.Validate()
+	case "ollama":
+		builder := BuildOllamaModel(profile.Model)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(profile.BaseURL)
+		}
+		if profile.Temperature != nil {
+			builder = builder.WithTemperature(*profile.Temperature)
+		}
+		if profile.MaxTokens > 0 {
+			builder = builder.WithTokens(0, profile.MaxTokens)
+		}
+		if stream != nil {
+			builder = builder.WithStream(stream)
+		}
+		return builder.Validate()
+	case "llamacpp":
+		builder := BuildLlamaCppModel(profile.Model)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(profile.BaseURL)
+		}
+		if profile.KeyEnv != "" {
+			builder = builder.WithKey(os.Getenv(profile.KeyEnv))
+		}
+		if profile.Temperature != nil {
+			builder = builder.WithTemperature(*profile.Temperature)
+		}
+		if profile.MaxTokens > 0 {
+			builder = builder.WithTokens(0, profile.MaxTokens)
+		}
+		if stream != nil {
+			builder = builder.WithStream(stream)
+		}
+		return builder.Validate()
+	case "anthropic":
+		builder := BuildAnthropicModel(os.Getenv(profile.KeyEnv), profile.Model)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(profile.BaseURL)
+		}
+		if profile.Temperature != nil {
+			builder = builder.WithTemperature(*profile.Temperature)
+		}
+		if profile.MaxTokens > 0 {
+			builder = builder.WithTokens(0, profile.MaxTokens)
+		}
+		if stream != nil {
+			builder = builder.WithStream(stream)
+		}
+		return builder.Validate()
+	default:
+		return nil, fmt.Errorf("unknown model provider '%s'", profile.Provider)
+	}
+}
+
+// BuildServer creates a client for the server of the provider named in profile, for checking that it is up and listing the models it serves.
+// Unlike BuildRaw, the profile does not need a model, so that the models can be listed before one is chosen.
+// The client implements ModelLister, and HealthChecker too if the provider supports it.
+func BuildServer(profile Profile) (ModelLister, error) {
+	switch profile.Provider {
+	case "ollama":
+		builder := BuildOllamaModel(profile.Model)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(profile.BaseURL)
+		}
+		return builder.model, nil
+	case "llamacpp":
+		builder := BuildLlamaCppModel(profile.Model)
+		if profile.BaseURL != "" {
+			builder = builder.WithURL(profile.BaseURL)
+		}
+		if profile.KeyEnv != "" {
+			builder = builder.WithKey(os.Getenv(profile.KeyEnv))
+		}
+		return builder.model, nil
+	case "openai", "anthropic":
+		return nil, fmt.Errorf("the %s provider does not support listing models", profile.Provider)
+	default:
+		return nil, fmt.Errorf("unknown model provider '%s'", profile.Provider)
+	}
+}
+
+// statusClient is used for health checks and listing models, which should answer quickly, so that a server that is down fails rather than hangs.
+// Chats use http.DefaultClient, as a model may take a long time to answer.
+var statusClient = &http.Client{Timeout: 10 * time.Second}
+
+// APIError is returned when a provider's server responds with an error status.
+type APIError struct {
+	Status int
+	// Kind is the provider's name for the kind of error, if it gave one.
+	Kind    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Kind != "" {
+		return fmt.Sprintf("api error %d (%s): %s", e.Status, e.Kind, e.Message)
+	}
+	return fmt.Sprintf("api error %d: %s", e.Status, e.Message)
+}
+
+func roleName(role jpf.Role) (string, error) {
+	switch role {
+	case jpf.SystemRole:
+		return "system", nil
+	case jpf.UserRole:
+		return "user", nil
+	case jpf.AssistantRole:
+		return "assistant", nil
+	default:
+		return "", fmt.Errorf("message role %d is not supported by this backend", role)
+	}
+}
+
+func chatMessages(msgs []jpf.Message) ([]map[string]string, error) {
+	out := make([]map[string]string, len(msgs))
+	for i, msg := range msgs {
+		role, err := roleName(msg.Role)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = map[string]string{"role": role, "content": msg.Content}
+	}
+	return out, nil
+}
+
+// doJSON sends body (if non-nil) as JSON to url with client and returns the response, failing on any non-2xx status.
+func doJSON(client *http.Client, method, url string, headers map[string]string, body any) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	return resp, nil
+}
+
+// getJSON fetches url with statusClient and decodes the JSON response into v.
+func getJSON(url string, headers map[string]string, v any) error {
+	resp, err := doJSON(statusClient, "GET", url, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// readLines calls fn with each non-empty line of r until fn returns false.
+func readLines(r io.Reader, fn func(line string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if more, err := fn(line); err != nil {
+			return err
+		} else if !more {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
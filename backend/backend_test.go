@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoshPattman/jpf"
+)
+
+func TestBuildServer(t *testing.T) {
+	ollama, _ := ollamaStub(t)
+	llamaCpp, _, _ := llamaCppStub(t, "ok")
+	cases := []struct {
+		name    string
+		profile Profile
+		models  string
+		err     string
+	}{
+		{name: "ollama without a model", profile: Profile{Provider: "ollama", BaseURL: ollama.URL}, models: "llama3:8b,qwen2:0.5b"},
+		{name: "llamacpp", profile: Profile{Provider: "llamacpp", BaseURL: llamaCpp.URL}, models: "qwen2.5-7b-instruct"},
+		{name: "openai", profile: Profile{Provider: "openai", Model: "gpt-4o"}, err: "does not support listing models"},
+		{name: "unknown", profile: Profile{Provider: "nope"}, err: "unknown model provider"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			lister, err := BuildServer(c.profile)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := lister.(HealthChecker).Health(); err != nil {
+				t.Errorf("expected the server to be healthy, got %v", err)
+			}
+			names, err := lister.ListModels()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(names, ",") != c.models {
+				t.Errorf("expected models %s, got %v", c.models, names)
+			}
+		})
+	}
+}
+
+func TestHealthTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	old := statusClient
+	statusClient = &http.Client{Timeout: 50 * time.Millisecond}
+	t.Cleanup(func() { statusClient = old })
+
+	lister, err := BuildServer(Profile{Provider: "ollama", BaseURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- lister.(HealthChecker).Health() }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "is not healthy") {
+			t.Errorf("expected a health error for a server that never answers, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check did not time out")
+	}
+}
+
+func TestBuildRawOpenAIURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("expected a request to /v1/chat/completions, got %s", r.URL.Path)
+		}
+		io.WriteString(w, `{"choices":[{"message":{"content":"hello"}}]}`)
+	}))
+	defer server.Close()
+
+	for _, base := range []string{server.URL + "/v1", server.URL + "/v1/"} {
+		model, err := BuildRaw(Profile{Provider: "openai", BaseURL: base, Model: "gpt-4o"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, resp, _, err := model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Content != "hello" {
+			t.Errorf("expected hello from %s, got %q", base, resp.Content)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// BudgetError is returned when a model refuses to make a call because one of its limits has been reached.
+type BudgetError struct {
+	// Limit is the limit that was reached: calls, tokens or cost.
+	Limit string
+	// Max is the limit, and Used is how much had been used when it was reached.
+	Max  float64
+	Used float64
+}
+
+func (e *BudgetError) Error() string {
+	switch e.Limit {
+	case "calls":
+		return fmt.Sprintf("call limit of %d reached", int(e.Max))
+	case "tokens":
+		return fmt.Sprintf("token limit of %d exceeded (used %d)", int(e.Max), int(e.Used))
+	default:
+		return fmt.Sprintf("cost limit of $%.4f exceeded (spent $%.4f)", e.Max, e.Used)
+	}
+}
+
+// budgetModel wraps a model, keeping a running total of calls, tokens and cost and refusing to go over its limits.
+type budgetModel struct {
+	model  jpf.Model
+	price  Price
+	limits Limits
+	calls  int
+	usage  jpf.Usage
+	cost   float64
+	// spent is set once a call has gone over the tokens or cost limit, and is returned by every call after it.
+	spent *BudgetError
+}
+
+// WithLimits wraps model so that it refuses to make calls once any of limits are reached, counting cost with price.
+// Calls that are refused fail with a *BudgetError without calling model.
+// The call that goes over the tokens or cost limit still returns its response, as it has already been paid for.
+func WithLimits(model jpf.Model, price Price, limits Limits) jpf.Model {
+	return &budgetModel{
+		model:  model,
+		price:  price,
+		limits: limits,
+	}
+}
+
+func (m *budgetModel) Tokens() (int, int) {
+	return m.model.Tokens()
+}
+
+func (m *budgetModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	if m.spent != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, m.spent
+	}
+	if m.limits.MaxCalls > 0 && m.calls >= m.limits.MaxCalls {
+		return nil, jpf.Message{}, jpf.Usage{}, &BudgetError{Limit: "calls", Max: float64(m.limits.MaxCalls), Used: float64(m.calls)}
+	}
+	aux, resp, usage, err := m.model.Respond(msgs)
+	m.calls++
+	m.usage = m.usage.Add(usage)
+	m.cost += m.price.Cost(usage.InputTokens, usage.OutputTokens)
+	if total := m.usage.InputTokens + m.usage.OutputTokens; m.limits.MaxTokens > 0 && total > m.limits.MaxTokens {
+		m.spent = &BudgetError{Limit: "tokens", Max: float64(m.limits.MaxTokens), Used: float64(total)}
+	} else if m.limits.MaxCost > 0 && m.cost > m.limits.MaxCost {
+		m.spent = &BudgetError{Limit: "cost", Max: m.limits.MaxCost, Used: m.cost}
+	}
+	return aux, resp, usage, err
+}
@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// fakeModel answers every call with reply, reporting usage, and counts how many calls it was sent.
+type fakeModel struct {
+	reply string
+	usage jpf.Usage
+	calls int
+}
+
+func (m *fakeModel) Tokens() (int, int) {
+	return 0, 0
+}
+
+func (m *fakeModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	m.calls++
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: m.reply}, m.usage, nil
+}
+
+func TestBudgetModel(t *testing.T) {
+	cases := []struct {
+		name   string
+		price  Price
+		limits Limits
+		// answered is how many calls get a response before the budget error, and sent is how many reach the model.
+		answered, sent int
+		limit          string
+	}{
+		{name: "calls", limits: Limits{MaxCalls: 2}, answered: 2, sent: 2, limit: "calls"},
+		{name: "tokens", limits: Limits{MaxTokens: 25}, answered: 3, sent: 3, limit: "tokens"},
+		{name: "cost", price: Price{Input: 1_000_000}, limits: Limits{MaxCost: 15}, answered: 2, sent: 2, limit: "cost"},
+		{name: "no limits", answered: 5, sent: 5},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fake := &fakeModel{reply: "hi", usage: jpf.Usage{InputTokens: 8, OutputTokens: 2}}
+			model := WithLimits(fake, c.price, c.limits)
+			answered := 0
+			var budgetErr *BudgetError
+			for range 5 {
+				_, resp, _, err := model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hello"}})
+				if err != nil {
+					if !errors.As(err, &budgetErr) {
+						t.Fatalf("expected a *BudgetError, got %v", err)
+					}
+					continue
+				}
+				if budgetErr != nil {
+					t.Fatalf("call was answered after the budget was used up")
+				}
+				if resp.Content != "hi" {
+					t.Fatalf("expected the model's response, got %q", resp.Content)
+				}
+				answered++
+			}
+			if answered != c.answered {
+				t.Errorf("expected %d calls to be answered, got %d", c.answered, answered)
+			}
+			if fake.calls != c.sent {
+				t.Errorf("expected %d calls to reach the model, got %d", c.sent, fake.calls)
+			}
+			if c.limit == "" && budgetErr != nil {
+				t.Errorf("expected no budget error, got %v", budgetErr)
+			} else if c.limit != "" && (budgetErr == nil || budgetErr.Limit != c.limit) {
+				t.Errorf("expected the %s limit to be reached, got %v", c.limit, budgetErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/JoshPattman/jpf"
+)
+
+type LlamaCppModelBuilder struct {
+	model *llamaCppModel
+}
+
+// BuildLlamaCppModel creates a model that talks to a llama.cpp server (or any other OpenAI-compatible local server).
+// The model name may be empty, as llama.cpp serves whichever model it was started with.
+func BuildLlamaCppModel(modelName string) *LlamaCppModelBuilder {
+	return &LlamaCppModelBuilder{
+		model: &llamaCppModel{
+			model:   modelName,
+			url:     "http://localhost:8080",
+			headers: map[string]string{},
+		},
+	}
+}
+
+func (b *LlamaCppModelBuilder) Validate() (jpf.Model, error) {
+	return b.model, nil
+}
+
+// WithURL sets the base URL of the server, without the /v1 suffix.
+func (b *LlamaCppModelBuilder) WithURL(url string) *LlamaCppModelBuilder {
+	b.model.url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), "/v1")
+	return b
+}
+
+// WithKey sets the API key the server was started with, if any.
+func (b *LlamaCppModelBuilder) WithKey(key string) *LlamaCppModelBuilder {
+	if key != "" {
+		b.model.headers["Authorization"] = "Bearer " + key
+	}
+	return b
+}
+
+func (b *LlamaCppModelBuilder) WithTemperature(temp float64) *LlamaCppModelBuilder {
+	b.model.temperature = &temp
+	return b
+}
+
+func (b *LlamaCppModelBuilder) WithTokens(input, output int) *LlamaCppModelBuilder {
+	b.model.maxInput = input
+	b.model.maxOutput = output
+	return b
+}
+
+// WithStream makes the model stream its responses, writing each piece of text to w as it arrives.
+func (b *LlamaCppModelBuilder) WithStream(w io.Writer) *LlamaCppModelBuilder {
+	b.model.stream = w
+	return b
+}
+
+type llamaCppModel struct {
+	model       string
+	url         string
+	headers     map[string]string
+	temperature *float64
+	maxInput    int
+	maxOutput   int
+	stream      io.Writer
+}
+
+type llamaCppChatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (m *llamaCppModel) Tokens() (int, int) {
+	return m.maxInput, m.maxOutput
+}
+
+func (m *llamaCppModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	chatMsgs, err := chatMessages(msgs)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, err
+	}
+	body := map[string]any{
+		"messages": chatMsgs,
+		"stream":   m.stream != nil,
+	}
+	if m.model != "" {
+		body["model"] = m.model
+	}
+	if m.temperature != nil {
+		body["temperature"] = *m.temperature
+	}
+	if m.maxOutput > 0 {
+		body["max_tokens"] = m.maxOutput
+	}
+	if m.stream != nil {
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+	resp, err := doJSON(http.DefaultClient, "POST", m.url+"/v1/chat/completions", m.headers, body)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	content := strings.Builder{}
+	usage := jpf.Usage{}
+	handle := func(data string) error {
+		var chunk llamaCppChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse response: %s", data)
+		}
+		if chunk.Usage != nil {
+			usage = jpf.Usage{InputTokens: chunk.Usage.PromptTokens, OutputTokens: chunk.Usage.CompletionTokens}
+		}
+		for _, choice := range chunk.Choices {
+			text := choice.Message.Content + choice.Delta.Content
+			content.WriteString(text)
+			if m.stream != nil {
+				io.WriteString(m.stream, text)
+			}
+		}
+		return nil
+	}
+	if m.stream == nil {
+		var data json.RawMessage
+		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+			return nil, jpf.Message{}, usage, err
+		}
+		err = handle(string(data))
+	} else {
+		// Streamed responses are server-sent events, one JSON chunk per data line.
+		err = readLines(resp.Body, func(line string) (bool, error) {
+			data, ok := strings.CutPrefix(line, "data:")
+			if !ok {
+				return true, nil
+			}
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				return false, nil
+			}
+			return true, handle(data)
+		})
+	}
+	if m.stream != nil {
+		io.WriteString(m.stream, "\n")
+	}
+	if err != nil {
+		return nil, jpf.Message{}, usage, err
+	}
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: content.String()}, usage, nil
+}
+
+func (m *llamaCppModel) Health() error {
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := getJSON(m.url+"/health", m.headers, &status); err != nil {
+		return fmt.Errorf("llama.cpp server at %s is not healthy: %w", m.url, err)
+	}
+	if status.Status != "" && status.Status != "ok" {
+		return fmt.Errorf("llama.cpp server at %s is not ready: %s", m.url, status.Status)
+	}
+	return nil
+}
+
+func (m *llamaCppModel) ListModels() ([]string, error) {
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := getJSON(m.url+"/v1/models", m.headers, &models); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(models.Data))
+	for i, model := range models.Data {
+		names[i] = model.ID
+	}
+	return names, nil
+}
@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// llamaCppStub is a stand-in llama.cpp server. Chats are answered with the given lines, and /health with health.
+func llamaCppStub(t *testing.T, health string, chatLines ...string) (*httptest.Server, *map[string]any, *http.Header) {
+	body := map[string]any{}
+	header := http.Header{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		for _, line := range chatLines {
+			io.WriteString(w, line+"\n")
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if health != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		io.WriteString(w, `{"status":"`+health+`"}`)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":[{"id":"qwen2.5-7b-instruct"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &body, &header
+}
+
+func TestLlamaCppRespond(t *testing.T) {
+	server, body, header := llamaCppStub(t, "ok", `{"choices":[{"message":{"content":"hi there"}}],"usage":{"prompt_tokens":9,"completion_tokens":2}}`)
+	model, err := BuildLlamaCppModel("").WithURL(server.URL+"/v1").WithKey("secret").WithTokens(0, 32).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp, usage, err := model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hi there" {
+		t.Errorf("expected %q, got %q", "hi there", resp.Content)
+	}
+	if usage.InputTokens != 9 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage 9 in, 2 out, got %+v", usage)
+	}
+	if got := header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("expected the key as a bearer token, got %q", got)
+	}
+	if _, ok := (*body)["model"]; ok {
+		t.Errorf("expected no model to be sent when none was set")
+	}
+	if (*body)["max_tokens"] != float64(32) {
+		t.Errorf("expected max_tokens 32, got %v", (*body)["max_tokens"])
+	}
+}
+
+func TestLlamaCppStream(t *testing.T) {
+	server, body, _ := llamaCppStub(t, "ok",
+		`data: {"choices":[{"delta":{"content":"Hel"}}]}`,
+		``,
+		`: keep-alive`,
+		`data: {"choices":[{"delta":{"content":"lo"}}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2}}`,
+		`data: [DONE]`,
+	)
+	streamed := &strings.Builder{}
+	model, err := BuildLlamaCppModel("qwen").WithURL(server.URL).WithStream(streamed).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp, usage, err := model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello" {
+		t.Errorf("expected the deltas to be joined, got %q", resp.Content)
+	}
+	if streamed.String() != "Hello\n" {
+		t.Errorf("expected each delta to be streamed, got %q", streamed.String())
+	}
+	if usage.InputTokens != 5 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage 5 in, 2 out, got %+v", usage)
+	}
+	options, _ := json.Marshal((*body)["stream_options"])
+	if (*body)["stream"] != true || string(options) != `{"include_usage":true}` {
+		t.Errorf("expected a streamed request asking for usage, got %v", *body)
+	}
+}
+
+func TestLlamaCppHealthAndModels(t *testing.T) {
+	cases := []struct {
+		health string
+		err    string
+	}{
+		{health: "ok"},
+		{health: "loading model", err: "is not healthy"},
+	}
+	for _, c := range cases {
+		t.Run(c.health, func(t *testing.T) {
+			server, _, _ := llamaCppStub(t, c.health)
+			model, err := BuildLlamaCppModel("").WithURL(server.URL).Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = model.(HealthChecker).Health()
+			if c.err == "" && err != nil {
+				t.Errorf("expected the server to be healthy, got %v", err)
+			} else if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Errorf("expected an error containing %q, got %v", c.err, err)
+			}
+		})
+	}
+	server, _, _ := llamaCppStub(t, "ok")
+	model, err := BuildLlamaCppModel("").WithURL(server.URL).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := model.(ModelLister).ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "qwen2.5-7b-instruct" {
+		t.Errorf("expected the served model to be listed, got %v", names)
+	}
+}
+
+func TestLlamaCppHealthRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	model, err := BuildLlamaCppModel("").WithURL(url).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.(HealthChecker).Health()
+	if err == nil || !strings.Contains(err.Error(), "is not healthy") {
+		t.Errorf("expected a health error for a server that is down, got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/JoshPattman/jpf"
+)
+
+type OllamaModelBuilder struct {
+	model *ollamaModel
+}
+
+// BuildOllamaModel creates a model that talks to Ollama's native chat API.
+func BuildOllamaModel(modelName string) *OllamaModelBuilder {
+	return &OllamaModelBuilder{
+		model: &ollamaModel{
+			model: modelName,
+			url:   "http://localhost:11434",
+		},
+	}
+}
+
+func (b *OllamaModelBuilder) Validate() (jpf.Model, error) {
+	if b.model.model == "" {
+		return nil, fmt.Errorf("ollama backend requires a model name")
+	}
+	return b.model, nil
+}
+
+// WithURL sets the base URL of the Ollama server.
+func (b *OllamaModelBuilder) WithURL(url string) *OllamaModelBuilder {
+	b.model.url = strings.TrimSuffix(url, "/")
+	return b
+}
+
+func (b *OllamaModelBuilder) WithTemperature(temp float64) *OllamaModelBuilder {
+	b.model.temperature = &temp
+	return b
+}
+
+func (b *OllamaModelBuilder) WithTokens(input, output int) *OllamaModelBuilder {
+	b.model.maxInput = input
+	b.model.maxOutput = output
+	return b
+}
+
+// WithStream makes the model stream its responses, writing each piece of text to w as it arrives.
+func (b *OllamaModelBuilder) WithStream(w io.Writer) *OllamaModelBuilder {
+	b.model.stream = w
+	return b
+}
+
+type ollamaModel struct {
+	model       string
+	url         string
+	temperature *float64
+	maxInput    int
+	maxOutput   int
+	stream      io.Writer
+}
+
+type ollamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (m *ollamaModel) Tokens() (int, int) {
+	return m.maxInput, m.maxOutput
+}
+
+func (m *ollamaModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	chatMsgs, err := chatMessages(msgs)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, err
+	}
+	options := map[string]any{}
+	if m.temperature != nil {
+		options["temperature"] = *m.temperature
+	}
+	if m.maxOutput > 0 {
+		options["num_predict"] = m.maxOutput
+	}
+	body := map[string]any{
+		"model":    m.model,
+		"messages": chatMsgs,
+		"stream":   m.stream != nil,
+		"options":  options,
+	}
+	resp, err := doJSON(http.DefaultClient, "POST", m.url+"/api/chat", nil, body)
+	if err != nil {
+		return nil, jpf.Message{}, jpf.Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	// Non-streamed responses are a single object, streamed ones are one object per line, so both are read line by line.
+	content := strings.Builder{}
+	usage := jpf.Usage{}
+	err = readLines(resp.Body, func(line string) (bool, error) {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return false, fmt.Errorf("failed to parse response: %s", line)
+		}
+		if chunk.Error != "" {
+			return false, fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		content.WriteString(chunk.Message.Content)
+		if m.stream != nil {
+			io.WriteString(m.stream, chunk.Message.Content)
+		}
+		if chunk.Done {
+			usage = jpf.Usage{InputTokens: chunk.PromptEvalCount, OutputTokens: chunk.EvalCount}
+			return false, nil
+		}
+		return true, nil
+	})
+	if m.stream != nil {
+		io.WriteString(m.stream, "\n")
+	}
+	if err != nil {
+		return nil, jpf.Message{}, usage, err
+	}
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: content.String()}, usage, nil
+}
+
+func (m *ollamaModel) Health() error {
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := getJSON(m.url+"/api/version", nil, &version); err != nil {
+		return fmt.Errorf("ollama server at %s is not healthy: %w", m.url, err)
+	}
+	return nil
+}
+
+func (m *ollamaModel) ListModels() ([]string, error) {
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(m.url+"/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tags.Models))
+	for i, model := range tags.Models {
+		names[i] = model.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// ollamaStub is a stand-in Ollama server, answering chats with the given lines of JSON.
+func ollamaStub(t *testing.T, chatLines ...string) (*httptest.Server, *map[string]any) {
+	body := map[string]any{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+		for _, line := range chatLines {
+			io.WriteString(w, line+"\n")
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"0.5.1"}`)
+	})
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"models":[{"name":"llama3:8b"},{"name":"qwen2:0.5b"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func TestOllamaRespond(t *testing.T) {
+	server, body := ollamaStub(t, `{"message":{"content":"hi there"},"done":true,"prompt_eval_count":7,"eval_count":2}`)
+	model, err := BuildOllamaModel("llama3").WithURL(server.URL+"/").WithTemperature(0.5).WithTokens(0, 64).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp, usage, err := model.Respond([]jpf.Message{{Role: jpf.SystemRole, Content: "sys"}, {Role: jpf.UserRole, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "hi there" {
+		t.Errorf("expected %q, got %q", "hi there", resp.Content)
+	}
+	if usage.InputTokens != 7 || usage.OutputTokens != 2 {
+		t.Errorf("expected usage 7 in, 2 out, got %+v", usage)
+	}
+	if (*body)["stream"] != false || (*body)["model"] != "llama3" {
+		t.Errorf("expected a non-streamed request for llama3, got %v", *body)
+	}
+	options, _ := json.Marshal((*body)["options"])
+	if string(options) != `{"num_predict":64,"temperature":0.5}` {
+		t.Errorf("expected the temperature and token limit in the options, got %s", options)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	server, body := ollamaStub(t,
+		`{"message":{"content":"Hel"},"done":false}`,
+		`{"message":{"content":"lo"},"done":false}`,
+		`{"message":{"content":"!"},"done":true,"prompt_eval_count":4,"eval_count":3}`,
+		`{"message":{"content":"ignored"},"done":false}`,
+	)
+	streamed := &strings.Builder{}
+	model, err := BuildOllamaModel("llama3").WithURL(server.URL).WithStream(streamed).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, resp, usage, err := model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hello!" {
+		t.Errorf("expected the chunks to be joined up to the done one, got %q", resp.Content)
+	}
+	if streamed.String() != "Hello!\n" {
+		t.Errorf("expected each chunk to be streamed, got %q", streamed.String())
+	}
+	if usage.InputTokens != 4 || usage.OutputTokens != 3 {
+		t.Errorf("expected usage 4 in, 3 out, got %+v", usage)
+	}
+	if (*body)["stream"] != true {
+		t.Errorf("expected a streamed request")
+	}
+}
+
+func TestOllamaError(t *testing.T) {
+	server, _ := ollamaStub(t, `{"error":"model 'llama3' not found"}`)
+	model, err := BuildOllamaModel("llama3").WithURL(server.URL).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = model.Respond([]jpf.Message{{Role: jpf.UserRole, Content: "hi"}})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the server's error, got %v", err)
+	}
+}
+
+func TestOllamaHealthAndModels(t *testing.T) {
+	server, _ := ollamaStub(t)
+	model, err := BuildOllamaModel("llama3").WithURL(server.URL).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.(HealthChecker).Health(); err != nil {
+		t.Errorf("expected the server to be healthy, got %v", err)
+	}
+	names, err := model.(ModelLister).ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "llama3:8b,qwen2:0.5b" {
+		t.Errorf("expected both models to be listed, got %v", names)
+	}
+}
+
+func TestOllamaHealthRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	model, err := BuildOllamaModel("llama3").WithURL(url).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.(HealthChecker).Health()
+	if err == nil || !strings.Contains(err.Error(), "is not healthy") {
+		t.Errorf("expected a health error for a server that is down, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package backend
+
+import "os"
+
+// Profile describes which model to talk to and how.
+type Profile struct {
+	Provider    string   `toml:"provider"`
+	BaseURL     string   `toml:"base_url"`
+	Model       string   `toml:"model"`
+	KeyEnv      string   `toml:"key_env"`
+	Temperature *float64 `toml:"temperature"`
+	MaxTokens   int      `toml:"max_tokens"`
+	Stream      bool     `toml:"stream"`
+	Price       Price    `toml:"price"`
+	Limits      Limits   `toml:"limits"`
+}
+
+// Price is the cost of a model in dollars per million tokens.
+type Price struct {
+	Input  float64 `toml:"input"`
+	Output float64 `toml:"output"`
+}
+
+// Limits caps how much a single run may spend. A zero value means no limit.
+type Limits struct {
+	MaxCalls  int     `toml:"max_calls"`
+	MaxTokens int     `toml:"max_tokens"`
+	MaxCost   float64 `toml:"max_cost"`
+}
+
+// Cost returns the price of the given token counts.
+func (p Price) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1_000_000
+}
+
+// EnvProfile is the profile used when no other is chosen, built from the OPENAI_* environment variables.
+func EnvProfile() Profile {
+	return Profile{
+		BaseURL: os.Getenv("OPENAI_URL"),
+		Model:   os.Getenv("OPENAI_MODEL"),
+	}.WithDefaults()
+}
+
+// WithDefaults fills in the provider, key variable and model of p if they are not set.
+func (p Profile) WithDefaults() Profile {
+	if p.Provider == "" {
+		p.Provider = "openai"
+	}
+	switch p.Provider {
+	case "openai":
+		if p.KeyEnv == "" {
+			p.KeyEnv = "OPENAI_KEY"
+		}
+		if p.Model == "" {
+			p.Model = "gpt-4o-mini"
+		}
+	case "anthropic":
+		if p.KeyEnv == "" {
+			p.KeyEnv = "ANTHROPIC_API_KEY"
+		}
+		if p.Model == "" {
+			p.Model = "claude-3-5-haiku-latest"
+		}
+	}
+	return p
+}
@@ -0,0 +1,110 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// Exchange is a question asked of a model and the answer it gave.
+type Exchange struct {
+	// System is the system prompt. An empty System matches any system prompt, which is handy for writing mocks by hand.
+	System string `json:"system,omitempty"`
+	// User is the message the statement sent, such as the interpolated text of a let statement.
+	User     string `json:"user"`
+	Response string `json:"response"`
+}
+
+// ReplayMissError is returned by a replay model when it has no answer left for a question.
+type ReplayMissError struct {
+	User string
+}
+
+func (e *ReplayMissError) Error() string {
+	return fmt.Sprintf("no recorded response for %q", e.User)
+}
+
+// replayModel answers with recorded exchanges rather than calling a real model.
+type replayModel struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+	used      []bool
+}
+
+// NewReplayModel creates a model that answers each question with the first unused exchange that matches it.
+// Each exchange is only used once, so a question asked several times, such as a while condition, gets each of its answers in turn.
+// Questions with no answer left fail with a *ReplayMissError.
+func NewReplayModel(exchanges []Exchange) jpf.Model {
+	return &replayModel{
+		exchanges: exchanges,
+		used:      make([]bool, len(exchanges)),
+	}
+}
+
+func (m *replayModel) Tokens() (int, int) {
+	return 0, 0
+}
+
+func (m *replayModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	system, user := splitMessages(msgs)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, ex := range m.exchanges {
+		if m.used[i] || ex.User != user || (ex.System != "" && ex.System != system) {
+			continue
+		}
+		m.used[i] = true
+		return nil, jpf.Message{Role: jpf.AssistantRole, Content: ex.Response}, jpf.Usage{}, nil
+	}
+	return nil, jpf.Message{}, jpf.Usage{}, &ReplayMissError{User: user}
+}
+
+// Recorder wraps a model, keeping each exchange it makes so that they can be replayed later.
+type Recorder struct {
+	model     jpf.Model
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+// NewRecorder creates a recorder that passes every question on to model.
+func NewRecorder(model jpf.Model) *Recorder {
+	return &Recorder{model: model}
+}
+
+func (r *Recorder) Tokens() (int, int) {
+	return r.model.Tokens()
+}
+
+func (r *Recorder) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	aux, resp, usage, err := r.model.Respond(msgs)
+	if err != nil {
+		return aux, resp, usage, err
+	}
+	system, user := splitMessages(msgs)
+	r.mu.Lock()
+	r.exchanges = append(r.exchanges, Exchange{System: system, User: user, Response: resp.Content})
+	r.mu.Unlock()
+	return aux, resp, usage, nil
+}
+
+// Exchanges returns every successful exchange made so far, in order.
+func (r *Recorder) Exchanges() []Exchange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Exchange(nil), r.exchanges...)
+}
+
+// splitMessages finds the system prompt and the last user message in msgs.
+func splitMessages(msgs []jpf.Message) (string, string) {
+	var system, user string
+	for _, msg := range msgs {
+		switch msg.Role {
+		case jpf.SystemRole:
+			system = msg.Content
+		case jpf.UserRole:
+			user = msg.Content
+		}
+	}
+	return system, user
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+const configFileName = "hellm.toml"
+
+// Config is a project-level hellm.toml file.
+type Config struct {
+	// Path is the file the config was loaded from, or empty if no file was found.
+	Path string `toml:"-"`
+	// Defaults are default values for CLI flags, keyed by flag name (e.g. profile = "local-llama").
+	Defaults map[string]any `toml:"defaults"`
+	// Profiles are the named model profiles that can be selected with --profile.
+	Profiles map[string]backend.Profile `toml:"profiles"`
+	// Prompts is a prompt file overriding the built-in prompts for the project, relative to the config file.
+	Prompts string `toml:"prompts"`
+}
+
+// FindConfig searches for a hellm.toml in dir and each of its parents, returning an empty config if there is none.
+func FindConfig(dir string) (Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Config{}, err
+	}
+	for {
+		path := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(path); err == nil {
+			return LoadConfig(path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return Config{}, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Config{}, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads the config file at path.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		return Config{}, fmt.Errorf("error reading config file '%s': %w", path, err)
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// Profile returns the named profile. An empty name selects the profile named by the profile default,
+// falling back to one built from the OPENAI_* environment variables.
+func (c Config) Profile(name string) (backend.Profile, error) {
+	if name == "" {
+		if def, ok := c.Defaults["profile"]; ok {
+			name = fmt.Sprint(def)
+		}
+	}
+	if name == "" {
+		return backend.EnvProfile(), nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if c.Path == "" {
+			return backend.Profile{}, fmt.Errorf("profile '%s' requested but no %s was found", name, configFileName)
+		}
+		return backend.Profile{}, fmt.Errorf("profile '%s' is not defined in '%s'", name, c.Path)
+	}
+	return profile.WithDefaults(), nil
+}
+
+// LoadPrompts returns the built-in prompts with the project's prompt file, then the given run's prompt file (if any), applied on top.
+func (c Config) LoadPrompts(runPromptsPath string) (*interpreter.Prompts, error) {
+	prompts := interpreter.DefaultPrompts()
+	if c.Prompts != "" {
+		path := c.Prompts
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(c.Path), path)
+		}
+		if err := prompts.Override(path); err != nil {
+			return nil, err
+		}
+	}
+	if runPromptsPath != "" {
+		if err := prompts.Override(runPromptsPath); err != nil {
+			return nil, err
+		}
+	}
+	return prompts, nil
+}
+
+// ApplyDefaults sets any flag in fs that was not given on the command line to its value from the config defaults.
+// Defaults for flags that fs does not define are ignored, as they may belong to another subcommand.
+func (c Config) ApplyDefaults(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, val := range c.Defaults {
+		if set[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, fmt.Sprint(val)); err != nil {
+			return fmt.Errorf("invalid default for flag '%s' in '%s': %w", name, c.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JoshPattman/hellm"
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/debug"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+func cmdDap(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: hellm dap")
+	}
+	return debug.NewServer(os.Stdin, os.Stdout, launchForDebug).Serve()
+}
+
+// launchForDebug gets a program ready to run in the debugger, with the config of the project it is in.
+// Programs being debugged have no stdin, as it is used to talk to the editor.
+func launchForDebug(req debug.LaunchRequest, debugger interpreter.Debugger, stdout, stderr io.Writer) (func() error, error) {
+	cfg, err := FindConfig(filepath.Dir(req.Program))
+	if err != nil {
+		return nil, err
+	}
+	profile, err := cfg.Profile(req.Profile)
+	if err != nil {
+		return nil, err
+	}
+	prompts, err := cfg.LoadPrompts("")
+	if err != nil {
+		return nil, err
+	}
+	model, err := backend.BuildRaw(profile, nil)
+	if err != nil {
+		return nil, err
+	}
+	content, err := readFile(req.Program)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parser.ParseSource(content)
+	if err != nil {
+		return nil, err
+	}
+	fsRoot := req.FSRoot
+	if fsRoot == "" {
+		fsRoot = filepath.Dir(req.Program)
+	}
+	rt, err := hellm.BuildRuntime(model).
+		WithStdin(strings.NewReader("")).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithLimits(profile.Price, profile.Limits).
+		WithPrompts(prompts).
+		WithFSRoot(fsRoot, false).
+		WithDebugger(debugger).
+		Validate()
+	if err != nil {
+		return nil, fmt.Errorf("error creating runtime: %w", err)
+	}
+	return func() error {
+		return rt.Run(req.Program, parsed, req.Args...)
+	}, nil
+}
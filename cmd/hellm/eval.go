@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/JoshPattman/hellm"
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+// evalCase is a row of an eval dataset.
+type evalCase struct {
+	Name string `json:"name"`
+	// Inputs are the function's arguments, either as a list or as an object keyed by argument name.
+	Inputs json.RawMessage `json:"inputs"`
+	// Expected is the exact output wanted, ignoring surrounding whitespace.
+	Expected *string `json:"expected"`
+	// Matches is a regular expression the output must match.
+	Matches string `json:"matches"`
+	// Judge is a rubric for the judge model, used instead of the --judge rubric.
+	Judge string `json:"judge"`
+
+	args    []string
+	matches *regexp.Regexp
+}
+
+// evalVariant is one way of running the function being evaluated.
+type evalVariant struct {
+	Label       string
+	Fn          string
+	ProfileName string
+	Profile     backend.Profile
+	Prompts     *interpreter.Prompts
+	model       jpf.Model
+}
+
+// evalResult is how a variant did on a case.
+type evalResult struct {
+	Output   string
+	Passed   bool
+	Reason   string
+	Duration time.Duration
+	Usage    jpf.Usage
+	Cost     float64
+	// JudgeCost is what judging the output cost.
+	JudgeCost float64
+}
+
+// evalJudge is the model that judges outputs against rubrics.
+type evalJudge struct {
+	model   jpf.Model
+	price   backend.Price
+	prompts *interpreter.Prompts
+	rubric  string
+}
+
+func cmdEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	fn := fs.String("fn", "", "name of the function to evaluate")
+	datasetPath := fs.String("dataset", "", "JSONL file of cases, each with inputs and what to expect")
+	judgeRubric := fs.String("judge", "", "rubric the judge model checks every output against")
+	profileName := fs.String("profile", "", "name of the profile in hellm.toml to run the function with")
+	promptsPath := fs.String("prompts", "", "prompt file overriding the built-in prompts")
+	judgeProfileName := fs.String("judge-profile", "", "name of the profile to judge outputs with (defaults to --profile)")
+	compareFn := fs.String("compare-fn", "", "function to compare against, such as a new version of the prompt")
+	compareProfile := fs.String("compare-profile", "", "profile to compare against")
+	comparePrompts := fs.String("compare-prompts", "", "prompt file to compare against")
+	parallel := fs.Int("parallel", 1, "number of cases to run at once")
+	jsonPath := fs.String("json", "", "file to write the results to as JSON")
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("must provide the file that defines the function")
+	}
+	if *fn == "" || *datasetPath == "" {
+		return fmt.Errorf("--fn and --dataset are required")
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	fileName := args[0]
+	cfg, err := loadConfig(fs, filepath.Dir(fileName))
+	if err != nil {
+		return err
+	}
+	content, err := readFile(fileName)
+	if err != nil {
+		return err
+	}
+	code, err := parser.ParseSource(content)
+	if err != nil {
+		return err
+	}
+	code = declarations(code)
+
+	variants := []*evalVariant{{Fn: *fn, ProfileName: *profileName}}
+	if *compareFn != "" || *compareProfile != "" || *comparePrompts != "" {
+		b := *variants[0]
+		if *compareFn != "" {
+			b.Fn = *compareFn
+		}
+		if *compareProfile != "" {
+			b.ProfileName = *compareProfile
+		}
+		variants = append(variants, &b)
+	}
+	for i, v := range variants {
+		path := *promptsPath
+		if i == 1 && *comparePrompts != "" {
+			path = *comparePrompts
+		}
+		if v.Prompts, err = cfg.LoadPrompts(path); err != nil {
+			return err
+		}
+		if v.Profile, err = cfg.Profile(v.ProfileName); err != nil {
+			return err
+		}
+		if v.model, err = backend.BuildRaw(v.Profile, nil); err != nil {
+			return err
+		}
+		v.Label = variantLabel(string(rune('A'+i)), v, path)
+	}
+
+	cases, err := readEvalCases(*datasetPath, code, *fn)
+	if err != nil {
+		return err
+	}
+	judge := &evalJudge{rubric: *judgeRubric}
+	if needsJudge(cases, *judgeRubric) {
+		if *judgeProfileName == "" {
+			*judgeProfileName = *profileName
+		}
+		profile, err := cfg.Profile(*judgeProfileName)
+		if err != nil {
+			return err
+		}
+		if judge.model, err = backend.BuildRaw(profile, nil); err != nil {
+			return err
+		}
+		judge.price = profile.Price
+		if judge.prompts, err = cfg.LoadPrompts(""); err != nil {
+			return err
+		}
+	}
+
+	results := runEval(fileName, code, variants, cases, judge, *parallel)
+	writeEvalTable(os.Stdout, variants, cases, results)
+	if *jsonPath != "" {
+		f, err := os.Create(*jsonPath)
+		if err != nil {
+			return fmt.Errorf("error creating '%s': %w", *jsonPath, err)
+		}
+		defer f.Close()
+		if err := writeEvalJSON(f, variants, cases, results); err != nil {
+			return fmt.Errorf("error writing '%s': %w", *jsonPath, err)
+		}
+	}
+	return nil
+}
+
+// declarations keeps the function definitions and imports of a program, so that its functions can be called without running it.
+func declarations(code []parser.ASTNode) []parser.ASTNode {
+	return slices.DeleteFunc(slices.Clone(code), func(node parser.ASTNode) bool {
+		switch node.(type) {
+		case parser.FuncDefNode, parser.ImportNode:
+			return false
+		}
+		return true
+	})
+}
+
+func variantLabel(letter string, v *evalVariant, promptsPath string) string {
+	label := letter + ": " + v.Fn
+	if v.ProfileName != "" {
+		label += " @" + v.ProfileName
+	}
+	if promptsPath != "" {
+		label += " (" + promptsPath + ")"
+	}
+	return label
+}
+
+func readEvalCases(path string, code []parser.ASTNode, fn string) ([]*evalCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dataset '%s': %w", path, err)
+	}
+	defer f.Close()
+	var argNames []string
+	for _, node := range code {
+		if def, ok := node.(parser.FuncDefNode); ok && def.Ident == fn {
+			argNames = def.Args
+		}
+	}
+	var cases []*evalCase
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		c := &evalCase{}
+		if err := json.Unmarshal(scanner.Bytes(), c); err != nil {
+			return nil, fmt.Errorf("error reading dataset '%s' at line %d: %w", path, line, err)
+		}
+		if err := c.parseInputs(argNames); err != nil {
+			return nil, fmt.Errorf("error reading dataset '%s' at line %d: %w", path, line, err)
+		}
+		if c.Matches != "" {
+			if c.matches, err = regexp.Compile(c.Matches); err != nil {
+				return nil, fmt.Errorf("error reading dataset '%s' at line %d: invalid matches pattern: %w", path, line, err)
+			}
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("#%d", len(cases)+1)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading dataset '%s': %w", path, err)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("dataset '%s' has no cases", path)
+	}
+	return cases, nil
+}
+
+// parseInputs turns the case's inputs into arguments for a function with the given argument names.
+// argNames is nil if the function is not defined in the file itself, in which case inputs must be a list.
+func (c *evalCase) parseInputs(argNames []string) error {
+	if len(c.Inputs) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(c.Inputs, &c.args); err == nil {
+		return nil
+	}
+	var named map[string]string
+	if err := json.Unmarshal(c.Inputs, &named); err != nil {
+		return fmt.Errorf("inputs must be a list of strings or an object of strings")
+	}
+	if argNames == nil {
+		return fmt.Errorf("inputs must be a list for functions that are not defined in the file")
+	}
+	for name := range named {
+		if !slices.Contains(argNames, name) {
+			return fmt.Errorf("the function has no argument called %s", name)
+		}
+	}
+	c.args = make([]string, len(argNames))
+	for i, name := range argNames {
+		val, ok := named[name]
+		if !ok {
+			return fmt.Errorf("missing input for argument %s", name)
+		}
+		c.args[i] = val
+	}
+	return nil
+}
+
+func needsJudge(cases []*evalCase, rubric string) bool {
+	if rubric != "" {
+		return true
+	}
+	return slices.ContainsFunc(cases, func(c *evalCase) bool { return c.Judge != "" })
+}
+
+// runEval runs every variant on every case, with up to parallel at once, returning results[case][variant].
+func runEval(fileName string, code []parser.ASTNode, variants []*evalVariant, cases []*evalCase, judge *evalJudge, parallel int) [][]evalResult {
+	results := make([][]evalResult, len(cases))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, c := range cases {
+		results[i] = make([]evalResult, len(variants))
+		for j, v := range variants {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i][j] = runEvalCase(fileName, code, v, c, judge)
+			}()
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// runEvalCase calls the variant's function with the case's inputs in a runtime of its own, and checks its output.
+func runEvalCase(fileName string, code []parser.ASTNode, v *evalVariant, c *evalCase, judge *evalJudge) evalResult {
+	counter := &usageCounter{model: v.model}
+	rt, err := hellm.BuildRuntime(counter).
+		WithStdin(strings.NewReader("")).
+		WithStdout(io.Discard).
+		WithStderr(io.Discard).
+		WithPrompts(v.Prompts).
+		WithLimits(v.Profile.Price, v.Profile.Limits).
+		WithFSRoot(filepath.Dir(fileName), true).
+		Validate()
+	if err != nil {
+		return evalResult{Reason: err.Error()}
+	}
+	if err := rt.Run(fileName, code); err != nil {
+		return evalResult{Reason: err.Error()}
+	}
+	start := time.Now()
+	vals, err := rt.Call(v.Fn, c.args...)
+	result := evalResult{
+		Duration: time.Since(start),
+		Usage:    counter.Usage(),
+	}
+	result.Cost = v.Profile.Price.Cost(result.Usage.InputTokens, result.Usage.OutputTokens)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	result.Output = strings.Join(vals, "\n")
+	result.Passed, result.Reason, result.JudgeCost = c.check(result.Output, judge)
+	return result
+}
+
+// check reports whether output passes every check the case asks for, and if not, why.
+func (c *evalCase) check(output string, judge *evalJudge) (bool, string, float64) {
+	if c.Expected != nil && strings.TrimSpace(output) != strings.TrimSpace(*c.Expected) {
+		return false, fmt.Sprintf("expected %q, got %q", *c.Expected, output), 0
+	}
+	if c.matches != nil && !c.matches.MatchString(output) {
+		return false, fmt.Sprintf("%q does not match %q", output, c.Matches), 0
+	}
+	rubric := c.Judge
+	if rubric == "" {
+		rubric = judge.rubric
+	}
+	if rubric == "" {
+		return true, "", 0
+	}
+	passed, cost, err := judge.judge(rubric, c, output)
+	if err != nil {
+		return false, "judge failed: " + err.Error(), cost
+	}
+	if !passed {
+		return false, fmt.Sprintf("judged as not meeting %q: %q", rubric, output), cost
+	}
+	return true, "", cost
+}
+
+// judge asks the judge model whether output meets rubric, giving it the case's inputs and expected output to go on.
+func (j *evalJudge) judge(rubric string, c *evalCase, output string) (bool, float64, error) {
+	vars := []interpreter.PromptVariable{{Name: "output", Value: output}}
+	if len(c.Inputs) > 0 {
+		vars = append(vars, interpreter.PromptVariable{Name: "inputs", Value: string(c.Inputs)})
+	}
+	if c.Expected != nil {
+		vars = append(vars, interpreter.PromptVariable{Name: "expected", Value: *c.Expected})
+	}
+	system, err := j.prompts.Render("assert", vars)
+	if err != nil {
+		return false, 0, err
+	}
+	_, resp, usage, err := j.model.Respond([]jpf.Message{
+		{Role: jpf.SystemRole, Content: system},
+		{Role: jpf.UserRole, Content: rubric},
+	})
+	cost := j.price.Cost(usage.InputTokens, usage.OutputTokens)
+	if err != nil {
+		return false, cost, err
+	}
+	if strings.Contains(resp.Content, "EVALUATE_TRUE") {
+		return true, cost, nil
+	} else if strings.Contains(resp.Content, "EVALUATE_FALSE") {
+		return false, cost, nil
+	}
+	return false, cost, errors.New("llm did not decide")
+}
+
+// usageCounter wraps a model, adding up the tokens it uses.
+type usageCounter struct {
+	model jpf.Model
+	mu    sync.Mutex
+	usage jpf.Usage
+}
+
+func (m *usageCounter) Tokens() (int, int) {
+	return m.model.Tokens()
+}
+
+func (m *usageCounter) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	aux, resp, usage, err := m.model.Respond(msgs)
+	m.mu.Lock()
+	m.usage = m.usage.Add(usage)
+	m.mu.Unlock()
+	return aux, resp, usage, err
+}
+
+func (m *usageCounter) Usage() jpf.Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// evalSummary is how a variant did over the whole dataset.
+type evalSummary struct {
+	Label         string  `json:"label"`
+	Fn            string  `json:"fn"`
+	Profile       string  `json:"profile,omitempty"`
+	Passed        int     `json:"passed"`
+	Total         int     `json:"total"`
+	PassRate      float64 `json:"pass_rate"`
+	Cost          float64 `json:"cost"`
+	JudgeCost     float64 `json:"judge_cost"`
+	InputTokens   int     `json:"input_tokens"`
+	OutputTokens  int     `json:"output_tokens"`
+	MeanLatencyMS float64 `json:"mean_latency_ms"`
+	P50LatencyMS  float64 `json:"p50_latency_ms"`
+	MaxLatencyMS  float64 `json:"max_latency_ms"`
+}
+
+func summarise(v *evalVariant, j int, results [][]evalResult) evalSummary {
+	s := evalSummary{Label: v.Label, Fn: v.Fn, Profile: v.ProfileName, Total: len(results)}
+	var latencies []time.Duration
+	var total time.Duration
+	for _, row := range results {
+		r := row[j]
+		if r.Passed {
+			s.Passed++
+		}
+		s.Cost += r.Cost
+		s.JudgeCost += r.JudgeCost
+		s.InputTokens += r.Usage.InputTokens
+		s.OutputTokens += r.Usage.OutputTokens
+		latencies = append(latencies, r.Duration)
+		total += r.Duration
+	}
+	slices.Sort(latencies)
+	s.PassRate = float64(s.Passed) / float64(s.Total)
+	s.MeanLatencyMS = msSince(total / time.Duration(len(latencies)))
+	s.P50LatencyMS = msSince(latencies[len(latencies)/2])
+	s.MaxLatencyMS = msSince(latencies[len(latencies)-1])
+	return s
+}
+
+// writeEvalTable writes each case's result for each variant side by side, then a summary of each variant and the reasons for any failures.
+func writeEvalTable(w io.Writer, variants []*evalVariant, cases []*evalCase, results [][]evalResult) {
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprint(tw, "case")
+	for _, v := range variants {
+		fmt.Fprintf(tw, "\t%s", v.Label)
+	}
+	fmt.Fprintln(tw)
+	for i, c := range cases {
+		fmt.Fprint(tw, truncate(c.Name, 40))
+		for _, r := range results[i] {
+			status := "FAIL"
+			if r.Passed {
+				status = "PASS"
+			}
+			fmt.Fprintf(tw, "\t%s  %.2fs  $%.6f", status, r.Duration.Seconds(), r.Cost)
+		}
+		fmt.Fprintln(tw)
+	}
+	summaries := make([]evalSummary, len(variants))
+	for j, v := range variants {
+		summaries[j] = summarise(v, j, results)
+	}
+	fmt.Fprintln(tw)
+	row := func(name string, cell func(s evalSummary) string) {
+		fmt.Fprint(tw, name)
+		for _, s := range summaries {
+			fmt.Fprintf(tw, "\t%s", cell(s))
+		}
+		fmt.Fprintln(tw)
+	}
+	row("pass rate", func(s evalSummary) string {
+		return fmt.Sprintf("%d/%d (%.0f%%)", s.Passed, s.Total, 100*s.PassRate)
+	})
+	row("cost", func(s evalSummary) string {
+		return fmt.Sprintf("$%.6f (+$%.6f judging)", s.Cost, s.JudgeCost)
+	})
+	row("tokens", func(s evalSummary) string {
+		return fmt.Sprintf("%d in, %d out", s.InputTokens, s.OutputTokens)
+	})
+	row("latency", func(s evalSummary) string {
+		return fmt.Sprintf("mean %.2fs  p50 %.2fs  max %.2fs", s.MeanLatencyMS/1000, s.P50LatencyMS/1000, s.MaxLatencyMS/1000)
+	})
+	tw.Flush()
+
+	header := false
+	for i, c := range cases {
+		for j, r := range results[i] {
+			if r.Passed {
+				continue
+			}
+			if !header {
+				fmt.Fprintln(w, "\nfailures:")
+				header = true
+			}
+			fmt.Fprintf(w, "  %s (%s): %s\n", c.Name, variants[j].Label, truncate(r.Reason, 200))
+		}
+	}
+}
+
+type evalJSONCase struct {
+	Name    string           `json:"name"`
+	Results []evalJSONResult `json:"results"`
+}
+
+type evalJSONResult struct {
+	Variant    string  `json:"variant"`
+	Output     string  `json:"output"`
+	Passed     bool    `json:"passed"`
+	Reason     string  `json:"reason,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Cost       float64 `json:"cost"`
+	JudgeCost  float64 `json:"judge_cost,omitempty"`
+}
+
+// writeEvalJSON writes the summary of each variant and every result as a single JSON object.
+func writeEvalJSON(w io.Writer, variants []*evalVariant, cases []*evalCase, results [][]evalResult) error {
+	report := struct {
+		Variants []evalSummary  `json:"variants"`
+		Cases    []evalJSONCase `json:"cases"`
+	}{}
+	for j, v := range variants {
+		report.Variants = append(report.Variants, summarise(v, j, results))
+	}
+	for i, c := range cases {
+		jc := evalJSONCase{Name: c.Name}
+		for j, r := range results[i] {
+			jc.Results = append(jc.Results, evalJSONResult{
+				Variant:    variants[j].Label,
+				Output:     r.Output,
+				Passed:     r.Passed,
+				Reason:     r.Reason,
+				DurationMS: msSince(r.Duration),
+				Cost:       r.Cost,
+				JudgeCost:  r.JudgeCost,
+			})
+		}
+		report.Cases = append(report.Cases, jc)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(report)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/jpf"
+)
+
+func TestEvalCaseParseInputs(t *testing.T) {
+	cases := []struct {
+		name     string
+		inputs   string
+		argNames []string
+		args     []string
+		err      string
+	}{
+		{name: "no inputs", argNames: []string{"a"}},
+		{name: "list", inputs: `["x", "y"]`, argNames: []string{"a", "b"}, args: []string{"x", "y"}},
+		{name: "list for an imported function", inputs: `["x"]`, args: []string{"x"}},
+		{name: "named", inputs: `{"b": "y", "a": "x"}`, argNames: []string{"a", "b"}, args: []string{"x", "y"}},
+		{name: "missing arg", inputs: `{"a": "x"}`, argNames: []string{"a", "b"}, err: "missing input for argument b"},
+		{name: "unknown arg", inputs: `{"a": "x", "c": "z"}`, argNames: []string{"a"}, err: "the function has no argument called c"},
+		{name: "named for an imported function", inputs: `{"a": "x"}`, err: "inputs must be a list"},
+		{name: "not strings", inputs: `[1, 2]`, argNames: []string{"a", "b"}, err: "inputs must be a list of strings or an object of strings"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ec := &evalCase{Inputs: json.RawMessage(c.inputs)}
+			err := ec.parseInputs(c.argNames)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(ec.args, c.args) {
+				t.Errorf("expected args %q, got %q", c.args, ec.args)
+			}
+		})
+	}
+}
+
+// judgeModel answers every judging call with reply, keeping the rubric it was last asked about.
+type judgeModel struct {
+	reply  string
+	rubric string
+}
+
+func (m *judgeModel) Tokens() (int, int) {
+	return 0, 0
+}
+
+func (m *judgeModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	m.rubric = msgs[len(msgs)-1].Content
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: m.reply}, jpf.Usage{InputTokens: 10, OutputTokens: 2}, nil
+}
+
+func TestEvalCaseCheck(t *testing.T) {
+	str := func(s string) *string { return &s }
+	cases := []struct {
+		name   string
+		c      evalCase
+		output string
+		// verdict is what the judge model answers, and judged is the rubric it should have been asked about, if any.
+		verdict, rubric, judged string
+		passed                  bool
+		reason                  string
+	}{
+		{name: "no checks", output: "anything", passed: true},
+		{name: "expected", c: evalCase{Expected: str("Paris")}, output: " Paris\n", passed: true},
+		{name: "expected differs", c: evalCase{Expected: str("Paris")}, output: "London", reason: `expected "Paris", got "London"`},
+		{name: "matches", c: evalCase{Matches: "^[0-9]+$"}, output: "42", passed: true},
+		{name: "does not match", c: evalCase{Matches: "^[0-9]+$"}, output: "forty two", reason: `"forty two" does not match "^[0-9]+$"`},
+		{name: "judge rubric", output: "hi", verdict: "EVALUATE_TRUE", rubric: "is polite", judged: "is polite", passed: true},
+		{name: "case rubric wins", c: evalCase{Judge: "is short"}, output: "hi", verdict: "EVALUATE_TRUE", rubric: "is polite", judged: "is short", passed: true},
+		{name: "judged false", c: evalCase{Judge: "is short"}, output: "hi", verdict: "EVALUATE_FALSE", judged: "is short", reason: `judged as not meeting "is short": "hi"`},
+		{name: "judge undecided", c: evalCase{Judge: "is short"}, output: "hi", verdict: "maybe", judged: "is short", reason: "judge failed: llm did not decide"},
+		{name: "fails before judging", c: evalCase{Expected: str("a"), Judge: "is short"}, output: "b", reason: `expected "a", got "b"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			model := &judgeModel{reply: c.verdict}
+			judge := &evalJudge{model: model, prompts: interpreter.DefaultPrompts(), rubric: c.rubric}
+			if c.c.Matches != "" {
+				c.c.matches = regexp.MustCompile(c.c.Matches)
+			}
+			passed, reason, _ := c.c.check(c.output, judge)
+			if passed != c.passed {
+				t.Errorf("expected passed to be %v, got %v (%s)", c.passed, passed, reason)
+			}
+			if reason != c.reason {
+				t.Errorf("expected reason %q, got %q", c.reason, reason)
+			}
+			if model.rubric != c.judged {
+				t.Errorf("expected the judge to be asked about %q, got %q", c.judged, model.rubric)
+			}
+		})
+	}
+}
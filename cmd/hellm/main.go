@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/JoshPattman/hellm"
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/lexer"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		failf("please provide a subcommand")
+	}
+	subCommand, commandArgs := os.Args[1], os.Args[2:]
+
+	switch subCommand {
+	case "help":
+		printUsage()
+	case "run":
+		err := cmdRun(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "test":
+		err := cmdTest(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "eval":
+		err := cmdEval(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "models":
+		err := cmdModels(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "prompts":
+		err := cmdPrompts(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "trace":
+		err := cmdTrace(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "dap":
+		err := cmdDap(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "doc":
+		err := cmdDoc(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "parse":
+		err := cmdParse(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "check":
+		err := cmdCheck(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "tokenize":
+		err := cmdTokenize(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	case "format":
+		err := cmdFormat(commandArgs)
+		if err != nil {
+			fail(err)
+		}
+	default:
+		printUsage()
+		failf("unrecognised subcommand '%s'", subCommand)
+	}
+}
+
+func cmdRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	profileName := fs.String("profile", "", "name of the profile in hellm.toml to run with")
+	maxCalls := fs.Int("max-calls", 0, "maximum number of LLM calls (0 for the profile default)")
+	maxTokens := fs.Int("max-tokens", 0, "maximum number of tokens used (0 for the profile default)")
+	maxCost := fs.Float64("max-cost", 0, "maximum spend in dollars (0 for the profile default)")
+	stream := fs.Bool("stream", false, "stream model responses to stderr as they arrive")
+	promptsPath := fs.String("prompts", "", "prompt file overriding the built-in prompts")
+	narrow := fs.Bool("narrow", false, "only send the model variables each statement lists with using")
+	output := fs.String("output", "text", "how print statements write output: text or json")
+	fsRoot := fs.String("fs-root", ".", "directory that scripts may read and write files in (empty to disable file access)")
+	dryRun := fs.Bool("dry-run", false, "report file writes instead of making them")
+	tracePath := fs.String("trace", "", "file to write a JSONL trace of the run to")
+	errorScope := fs.Bool("error-scope", false, "print the variables in scope where a runtime error happened, along with the call stack")
+	snapshotRun := fs.Bool("snapshot", false, "compare stdout, prints and the final scope with the script's snapshot file, failing if there is none")
+	updateSnapshots := fs.Bool("update-snapshots", false, "create or replace the script's snapshot file with this run")
+	replayPath := fs.String("replay", "", "replay file to answer model calls from instead of the model")
+	recordPath := fs.String("record", "", "replay file to record the model's answers to")
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a filename to run")
+	}
+	fileName := args[0]
+	cfg, err := loadConfig(fs, filepath.Dir(fileName))
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.Profile(*profileName)
+	if err != nil {
+		return err
+	}
+	prompts, err := cfg.LoadPrompts(*promptsPath)
+	if err != nil {
+		return err
+	}
+	if *stream {
+		profile.Stream = true
+	}
+	if *output != string(interpreter.TextOutput) && *output != string(interpreter.JSONOutput) {
+		return fmt.Errorf("unknown output mode '%s', expected text or json", *output)
+	}
+	if *maxCalls > 0 {
+		profile.Limits.MaxCalls = *maxCalls
+	}
+	if *maxTokens > 0 {
+		profile.Limits.MaxTokens = *maxTokens
+	}
+	if *maxCost > 0 {
+		profile.Limits.MaxCost = *maxCost
+	}
+	var streamTo io.Writer
+	if profile.Stream {
+		streamTo = os.Stderr
+	}
+	if *replayPath != "" && *recordPath != "" {
+		return fmt.Errorf("cannot both --replay and --record")
+	}
+	var model jpf.Model
+	var recorder *backend.Recorder
+	if *replayPath != "" {
+		entries, err := readReplayEntries(*replayPath)
+		if err != nil {
+			return err
+		}
+		exchanges := make([]backend.Exchange, len(entries))
+		for i, entry := range entries {
+			exchanges[i] = entry.Exchange
+		}
+		model = backend.NewReplayModel(exchanges)
+	} else {
+		raw, err := backend.BuildRaw(profile, streamTo)
+		if err != nil {
+			return err
+		}
+		model = raw
+		if *recordPath != "" {
+			recorder = backend.NewRecorder(raw)
+			model = recorder
+		}
+	}
+
+	content, err := readFile(fileName)
+	if err != nil {
+		fail(err)
+	}
+	parsed, err := parser.ParseSource(content)
+	if err != nil {
+		fail(err)
+	}
+	if interpreter.ParseScriptArgs(args[1:]).Help {
+		fmt.Print(interpreter.ScriptUsage(fileName, parsed))
+		return nil
+	}
+
+	builder := hellm.BuildRuntime(model).
+		WithLimits(profile.Price, profile.Limits).
+		WithPrompts(prompts).
+		WithOutput(interpreter.OutputMode(*output)).
+		WithFSRoot(*fsRoot, *dryRun)
+	if *narrow {
+		builder = builder.WithNarrow()
+	}
+	if *errorScope {
+		builder = builder.WithErrorScope()
+	}
+	var tracer *interpreter.JSONLTracer
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			return fmt.Errorf("error creating trace file '%s': %w", *tracePath, err)
+		}
+		defer f.Close()
+		tracer = interpreter.NewJSONLTracer(f)
+		builder = builder.WithTracer(tracer)
+	}
+	snapshotting := *snapshotRun || *updateSnapshots
+	var prints *printRecorder
+	stdout := &bytes.Buffer{}
+	if snapshotting {
+		prints = &printRecorder{}
+		if tracer != nil {
+			prints.next = tracer
+		}
+		builder = builder.WithTracer(prints).WithStdout(io.MultiWriter(os.Stdout, stdout))
+	}
+	rt, err := builder.Validate()
+	if err != nil {
+		return err
+	}
+	runErr := rt.Run(fileName, parsed, args[1:]...)
+	if tracer != nil && tracer.Err() != nil {
+		fmt.Fprintln(os.Stderr, "error writing trace:", tracer.Err())
+	}
+	if recorder != nil {
+		entries := []replayEntry{}
+		for _, ex := range recorder.Exchanges() {
+			entries = append(entries, replayEntry{Exchange: ex})
+		}
+		if err := writeReplayEntries(*recordPath, entries); err != nil {
+			return err
+		}
+	}
+	if snapshotting {
+		// A run that fails the same way as its snapshot still matches it
+		snap := takeSnapshot(stdout.String(), prints.prints, rt.Scope(), runErr)
+		return checkSnapshot(snapshotPath(fileName), snap, *updateSnapshots)
+	}
+	if runErr != nil {
+		fail(runErr)
+	}
+
+	return nil
+}
+
+func cmdModels(args []string) error {
+	fs := flag.NewFlagSet("models", flag.ExitOnError)
+	profileName := fs.String("profile", "", "name of the profile in hellm.toml to list models for")
+	fs.Parse(args)
+
+	cfg, err := loadConfig(fs, ".")
+	if err != nil {
+		return err
+	}
+	profile, err := cfg.Profile(*profileName)
+	if err != nil {
+		return err
+	}
+	lister, err := backend.BuildServer(profile)
+	if err != nil {
+		return err
+	}
+	if checker, ok := lister.(backend.HealthChecker); ok {
+		if err := checker.Health(); err != nil {
+			return err
+		}
+	}
+	models, err := lister.ListModels()
+	if err != nil {
+		return err
+	}
+	for _, name := range models {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func cmdPrompts(args []string) error {
+	if len(args) < 1 || args[0] != "dump" {
+		return fmt.Errorf("usage: hellm prompts dump [--prompts <file>]")
+	}
+	fs := flag.NewFlagSet("prompts dump", flag.ExitOnError)
+	promptsPath := fs.String("prompts", "", "prompt file overriding the built-in prompts")
+	fs.Parse(args[1:])
+
+	cfg, err := loadConfig(fs, ".")
+	if err != nil {
+		return err
+	}
+	prompts, err := cfg.LoadPrompts(*promptsPath)
+	if err != nil {
+		return err
+	}
+	return prompts.Dump(os.Stdout)
+}
+
+// loadConfig finds the config for dir, then fills in any flags in fs that it has defaults for.
+func loadConfig(fs *flag.FlagSet, dir string) (Config, error) {
+	cfg, err := FindConfig(dir)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := cfg.ApplyDefaults(fs); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func cmdDoc(args []string) error {
+	if len(args) < 1 || (args[0] != "std" && !interpreter.IsStdModule(args[0])) {
+		return fmt.Errorf("usage: hellm doc std|std/<module>")
+	}
+	docs, err := interpreter.StdDocs()
+	if err != nil {
+		return err
+	}
+	if args[0] != "std" {
+		docs = slices.DeleteFunc(docs, func(doc interpreter.ModuleDoc) bool { return doc.Name != args[0] })
+		if len(docs) == 0 {
+			return fmt.Errorf("there is no standard library module '%s'", args[0])
+		}
+	}
+	interpreter.WriteModuleDocs(os.Stdout, docs)
+	return nil
+}
+
+func cmdParse(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a filename to run")
+	}
+	fileName := args[0]
+	content, err := readFile(fileName)
+	if err != nil {
+		fail(err)
+	}
+	lexTokens, err := lexer.Lex(content)
+	if err != nil {
+		fail(err)
+	}
+
+	parsed, err := parser.Parse(lexTokens)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Print(parser.FormatProgram(parsed))
+
+	return nil
+}
+
+func cmdCheck(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a filename to check")
+	}
+	fileName := args[0]
+	content, err := readFile(fileName)
+	if err != nil {
+		fail(err)
+	}
+	lexTokens, err := lexer.Lex(content)
+	if err != nil {
+		fail(err)
+	}
+
+	parsed, err := parser.Parse(lexTokens)
+	if err != nil {
+		fail(err)
+	}
+
+	errs := parser.Check(parsed)
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", fileName, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems", len(errs))
+	}
+	return nil
+}
+
+func cmdTokenize(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a filename to run")
+	}
+	fileName := args[0]
+	content, err := readFile(fileName)
+	if err != nil {
+		fail(err)
+	}
+	lexTokens, err := lexer.Lex(content)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Println(lexer.FormatLexTokens(lexTokens))
+
+	return nil
+}
+
+func cmdFormat(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a filename to run")
+	}
+	fileName := args[0]
+	content, err := readFile(fileName)
+	if err != nil {
+		fail(err)
+	}
+	lexTokens, err := lexer.Lex(content)
+	if err != nil {
+		fail(err)
+	}
+
+	parsed, err := parser.Parse(lexTokens)
+	if err != nil {
+		fail(err)
+	}
+
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.WriteString(f, parser.FormatProgram(parsed))
+	return err
+}
+
+func readFile(fileName string) (string, error) {
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("source file '%s' does not exist", fileName)
+	} else if err != nil {
+		return "", errors.Join(fmt.Errorf("error reading source file '%s'", fileName), err)
+	}
+	return string(data), nil
+}
+
+func printUsage() {
+	fmt.Println("hellm - A language for 100x devs")
+	fmt.Println("usage:")
+	fmt.Println("$ hellm run [--profile <name>] [--max-calls <n>] [--max-tokens <n>] [--max-cost <dollars>] [--stream] [--prompts <file>] [--narrow] [--output text|json] [--fs-root <dir>] [--dry-run] [--trace <file>] [--error-scope] [--snapshot] [--update-snapshots] [--replay <file>] [--record <file>] <filename> [args...] [--help]")
+	fmt.Println("$ hellm test [--run <regexp>] [--parallel <n>] [-v] [--live] [--record] [--profile <name>] [--prompts <file>] [--narrow] [--junit <file>] [--json <file>] [paths...]")
+	fmt.Println("$ hellm eval --fn <name> --dataset <file> [--judge <rubric>] [--judge-profile <name>] [--profile <name>] [--prompts <file>] [--compare-fn <name>] [--compare-profile <name>] [--compare-prompts <file>] [--parallel <n>] [--json <file>] <filename>")
+	fmt.Println("$ hellm models [--profile <name>]")
+	fmt.Println("$ hellm prompts dump [--prompts <file>]")
+	fmt.Println("$ hellm trace show [--expand all|<ids>] [--html <file>] <trace file>")
+	fmt.Println("$ hellm dap")
+	fmt.Println("$ hellm doc std|std/<module>")
+	fmt.Println("$ hellm check <filename>")
+	fmt.Println("$ hellm tokenize <filename>")
+	fmt.Println("$ hellm parse <filename>")
+	fmt.Println("$ hellm format <filename>")
+	fmt.Println("$ hellm help")
+
+}
+
+func fail(err error) {
+	if err != nil {
+		fmt.Println("fatal error:", err)
+		var rerr *interpreter.RuntimeError
+		if errors.As(err, &rerr) {
+			fmt.Print(rerr.StackTrace())
+		}
+		os.Exit(1)
+	}
+}
+
+func failf(f string, args ...any) {
+	fail(fmt.Errorf(f, args...))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+// snapshot is what a run of a program did, as stored in its snapshot file.
+type snapshot struct {
+	Stdout string          `json:"stdout"`
+	Prints []snapshotPrint `json:"prints"`
+	// Scope is the global variables when the program finished, with hidden values replaced.
+	Scope map[string]string `json:"scope"`
+	// Error is why the program failed, if it did, with the message of any hidden error thrown replaced.
+	Error string `json:"error,omitempty"`
+}
+
+type snapshotPrint struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// snapshotPath is where the snapshot of the program at path is kept.
+func snapshotPath(path string) string {
+	return strings.TrimSuffix(path, ".hl") + ".snap.json"
+}
+
+// printRecorder is a tracer that keeps every print, passing all events on to next if it is set.
+type printRecorder struct {
+	next   interpreter.Tracer
+	prints []snapshotPrint
+}
+
+func (r *printRecorder) Trace(ev interpreter.Event) {
+	if ev.Kind == interpreter.Print {
+		r.prints = append(r.prints, snapshotPrint{Stream: ev.Stream, Text: ev.Text})
+	}
+	if r.next != nil {
+		r.next.Trace(ev)
+	}
+}
+
+// takeSnapshot builds the snapshot of a finished run.
+func takeSnapshot(stdout string, prints []snapshotPrint, scope *interpreter.Scope, runErr error) snapshot {
+	snap := snapshot{Stdout: stdout, Prints: prints, Scope: map[string]string{}}
+	if snap.Prints == nil {
+		snap.Prints = []snapshotPrint{}
+	}
+	for k, v := range scope.KVPs() {
+		if scope.IsHidden(k) {
+			v = "<hidden>"
+		}
+		snap.Scope[k] = v
+	}
+	if runErr != nil {
+		snap.Error = interpreter.RedactedError(runErr)
+	}
+	return snap
+}
+
+// checkSnapshot compares snap to the snapshot stored at path, failing with a diff if they differ.
+// If update is set, snap is stored instead, creating the file if need be. Without it a missing snapshot is an error, so a snapshot that was never committed cannot pass.
+func checkSnapshot(path string, snap snapshot, update bool) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(snap); err != nil {
+		return err
+	}
+	if update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("error writing snapshot '%s': %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "wrote snapshot '%s'\n", path)
+		return nil
+	}
+	stored, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("there is no snapshot '%s' (use --update-snapshots to create it)", path)
+	} else if err != nil {
+		return fmt.Errorf("error reading snapshot '%s': %w", path, err)
+	}
+	if bytes.Equal(stored, buf.Bytes()) {
+		return nil
+	}
+	return fmt.Errorf("run does not match snapshot '%s' (use --update-snapshots if the change is intended):\n%s", path, diffLines(string(stored), buf.String()))
+}
+
+// diffLines describes how to turn a into b, a line at a time, showing the lines that changed with two lines of context either side.
+func diffLines(a, b string) string {
+	as := strings.Split(strings.TrimSuffix(a, "\n"), "\n")
+	bs := strings.Split(strings.TrimSuffix(b, "\n"), "\n")
+	// lcs[i][j] is the length of the longest common subsequence of as[i:] and bs[j:]
+	lcs := make([][]int, len(as)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			if as[i] == bs[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var lines []string
+	for i, j := 0, 0; i < len(as) || j < len(bs); {
+		switch {
+		case i < len(as) && j < len(bs) && as[i] == bs[j]:
+			lines = append(lines, "  "+as[i])
+			i, j = i+1, j+1
+		case i < len(as) && (j == len(bs) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+as[i])
+			i++
+		default:
+			lines = append(lines, "+ "+bs[j])
+			j++
+		}
+	}
+	const context = 2
+	out := &strings.Builder{}
+	lastShown := -1
+	for i, line := range lines {
+		near := false
+		for k := max(0, i-context); k <= min(len(lines)-1, i+context); k++ {
+			if !strings.HasPrefix(lines[k], "  ") {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if lastShown >= 0 && i > lastShown+1 {
+			out.WriteString("  ...\n")
+		}
+		out.WriteString(line + "\n")
+		lastShown = i
+	}
+	return out.String()
+}
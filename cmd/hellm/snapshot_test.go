@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		diff string
+	}{
+		{name: "same", a: "a\nb\n", b: "a\nb\n", diff: ""},
+		{name: "changed", a: "a\nb\nc\n", b: "a\nx\nc\n", diff: "  a\n- b\n+ x\n  c\n"},
+		{name: "added", a: "a\n", b: "a\nb\n", diff: "  a\n+ b\n"},
+		{name: "removed", a: "a\nb\n", b: "b\n", diff: "- a\n  b\n"},
+		{
+			name: "far apart",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			b:    "0\n2\n3\n4\n5\n6\n7\n8\n0\n",
+			diff: "- 1\n+ 0\n  2\n  3\n  ...\n  7\n  8\n- 9\n+ 0\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if diff := diffLines(c.a, c.b); diff != c.diff {
+				t.Errorf("expected diff:\n%s\ngot:\n%s", c.diff, diff)
+			}
+		})
+	}
+}
+
+func TestCheckSnapshot(t *testing.T) {
+	stored := snapshot{Stdout: "hi\n", Prints: []snapshotPrint{{Stream: "stdout", Text: "hi"}}, Scope: map[string]string{"x": "hi"}}
+	changed := snapshot{Stdout: "bye\n", Prints: []snapshotPrint{{Stream: "stdout", Text: "bye"}}, Scope: map[string]string{"x": "bye"}}
+	cases := []struct {
+		name string
+		// existing is the snapshot already on disk, if any.
+		existing *snapshot
+		snap     snapshot
+		update   bool
+		err      string
+		// written is whether the file should hold snap afterwards.
+		written bool
+	}{
+		{name: "matches", existing: &stored, snap: stored},
+		{name: "differs", existing: &stored, snap: changed, err: "run does not match snapshot"},
+		{name: "missing", snap: stored, err: "use --update-snapshots to create it"},
+		{name: "missing with update", snap: stored, update: true, written: true},
+		{name: "differs with update", existing: &stored, snap: changed, update: true, written: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.snap.json")
+			var before []byte
+			if c.existing != nil {
+				if err := checkSnapshot(path, *c.existing, true); err != nil {
+					t.Fatalf("error writing the existing snapshot: %v", err)
+				}
+				before, _ = os.ReadFile(path)
+			}
+			err := checkSnapshot(path, c.snap, c.update)
+			if c.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)) {
+				t.Fatalf("expected an error containing %q, got %v", c.err, err)
+			}
+			after, readErr := os.ReadFile(path)
+			if c.existing == nil && !c.written {
+				if !os.IsNotExist(readErr) {
+					t.Fatalf("expected no snapshot to be written, got %v", readErr)
+				}
+				return
+			}
+			if c.written {
+				// The stored snapshot must now match snap without updating.
+				if err := checkSnapshot(path, c.snap, false); err != nil {
+					t.Errorf("expected the written snapshot to match the run: %v", err)
+				}
+			} else if string(after) != string(before) {
+				t.Errorf("expected the snapshot to be left alone, but it changed to:\n%s", after)
+			}
+		})
+	}
+}
+
+func TestCheckSnapshotDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.snap.json")
+	if err := checkSnapshot(path, snapshot{Stdout: "hi\n", Prints: []snapshotPrint{}, Scope: map[string]string{}}, true); err != nil {
+		t.Fatal(err)
+	}
+	err := checkSnapshot(path, snapshot{Stdout: "bye\n", Prints: []snapshotPrint{}, Scope: map[string]string{}}, false)
+	if err == nil {
+		t.Fatal("expected the changed stdout to fail the check")
+	}
+	for _, want := range []string{`-   "stdout": "hi\n",`, `+   "stdout": "bye\n",`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected the diff to contain %q, got:\n%s", want, err)
+		}
+	}
+}
+
+func TestTakeSnapshotHidesThrownError(t *testing.T) {
+	scope := interpreter.NewScope()
+	runErr := &interpreter.RuntimeError{Err: &interpreter.ThrownError{Message: "inner s3cr3t", Hidden: true}}
+	snap := takeSnapshot("", nil, scope, runErr)
+	if snap.Error != "<hidden>" {
+		t.Errorf("expected the hidden error to be replaced, got %q", snap.Error)
+	}
+}
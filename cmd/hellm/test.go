@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoshPattman/hellm"
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+// testFile is a parsed _test.hl file and the recorded model responses for its tests.
+type testFile struct {
+	Path string
+	Code []parser.ASTNode
+	// Tests are the names of the file's test blocks, in order.
+	Tests []string
+	// Replay is the recorded exchanges of each test.
+	Replay map[string][]backend.Exchange
+	// Err is why the file could not be loaded, in which case it has no tests.
+	Err error
+}
+
+// testResult is the outcome of running a single test.
+type testResult struct {
+	File     string
+	Name     string
+	Duration time.Duration
+	Output   string
+	Err      error
+	// Recorded is what the model was asked and answered, when recording.
+	Recorded []backend.Exchange
+}
+
+// replayEntry is a line of a replay file.
+type replayEntry struct {
+	// Test is the test that asked the question, or empty if it was recorded by hellm run.
+	Test string `json:"test,omitempty"`
+	backend.Exchange
+}
+
+// testOptions is how to run each test.
+type testOptions struct {
+	profile backend.Profile
+	live    bool
+	record  bool
+	prompts *interpreter.Prompts
+	narrow  bool
+}
+
+func cmdTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	profileName := fs.String("profile", "", "name of the profile in hellm.toml to use with --live or --record")
+	runPattern := fs.String("run", "", "only run tests whose name matches this regular expression")
+	parallel := fs.Int("parallel", 1, "number of tests to run at once")
+	verbose := fs.Bool("v", false, "print the output of every test, not just failing ones")
+	live := fs.Bool("live", false, "answer with the real model instead of the replay files")
+	record := fs.Bool("record", false, "answer with the real model, and save its answers to the replay files")
+	promptsPath := fs.String("prompts", "", "prompt file overriding the built-in prompts")
+	narrow := fs.Bool("narrow", false, "only send the model variables each statement lists with using")
+	junitPath := fs.String("junit", "", "file to write a JUnit XML report to")
+	jsonPath := fs.String("json", "", "file to write a JSON report to")
+	fs.Parse(args)
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1")
+	}
+	var filter *regexp.Regexp
+	if *runPattern != "" {
+		var err error
+		if filter, err = regexp.Compile(*runPattern); err != nil {
+			return fmt.Errorf("invalid --run pattern: %w", err)
+		}
+	}
+
+	cfg, err := loadConfig(fs, configDir(paths[0]))
+	if err != nil {
+		return err
+	}
+	prompts, err := cfg.LoadPrompts(*promptsPath)
+	if err != nil {
+		return err
+	}
+	opts := testOptions{
+		live:    *live || *record,
+		record:  *record,
+		prompts: prompts,
+		narrow:  *narrow,
+	}
+	if opts.live {
+		if opts.profile, err = cfg.Profile(*profileName); err != nil {
+			return err
+		}
+	}
+
+	files, err := findTestFiles(paths)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	results := runTests(files, filter, *parallel, opts, func(r testResult) {
+		printTestResult(r, *verbose)
+	})
+	duration := time.Since(start)
+
+	if opts.record {
+		for _, f := range files {
+			if err := writeReplay(f, results); err != nil {
+				return err
+			}
+		}
+	}
+	if *junitPath != "" {
+		if err := writeReport(*junitPath, results, duration, writeJUnit); err != nil {
+			return err
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeReport(*jsonPath, results, duration, writeJSONReport); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tests failed (%.2fs)", failed, len(results), duration.Seconds())
+	}
+	fmt.Printf("ok: %d tests passed (%.2fs)\n", len(results), duration.Seconds())
+	return nil
+}
+
+// configDir is the directory to look for hellm.toml from, for a path given to hellm test.
+func configDir(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path
+	}
+	return filepath.Dir(path)
+}
+
+// findTestFiles loads every _test.hl file in paths, looking through directories recursively.
+func findTestFiles(paths []string) ([]*testFile, error) {
+	var files []*testFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, loadTestFile(path))
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, "_test.hl") {
+				files = append(files, loadTestFile(p))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func loadTestFile(path string) *testFile {
+	f := &testFile{Path: path, Replay: map[string][]backend.Exchange{}}
+	content, err := readFile(path)
+	if err != nil {
+		f.Err = err
+		return f
+	}
+	if f.Code, err = parser.ParseSource(content); err != nil {
+		f.Err = err
+		return f
+	}
+	seen := map[string]bool{}
+	for _, node := range f.Code {
+		test, ok := node.(parser.TestNode)
+		if !ok {
+			continue
+		}
+		if seen[test.Name] {
+			f.Err = fmt.Errorf("%s: there is more than one test called '%s'", test.Pos, test.Name)
+			return f
+		}
+		seen[test.Name] = true
+		f.Tests = append(f.Tests, test.Name)
+	}
+	if f.Replay, err = readReplay(replayPath(path)); err != nil {
+		f.Err = err
+	}
+	return f
+}
+
+// replayPath is where the recorded model answers for the tests in the test file at path are kept.
+func replayPath(path string) string {
+	return strings.TrimSuffix(path, ".hl") + ".replay.jsonl"
+}
+
+func readReplay(path string) (map[string][]backend.Exchange, error) {
+	entries, err := readReplayEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	replay := map[string][]backend.Exchange{}
+	for _, entry := range entries {
+		replay[entry.Test] = append(replay[entry.Test], entry.Exchange)
+	}
+	return replay, nil
+}
+
+// readReplayEntries reads every line of a replay file, in order. A replay file that does not exist has no entries.
+func readReplayEntries(path string) ([]replayEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading replay file '%s': %w", path, err)
+	}
+	defer f.Close()
+	var entries []replayEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry replayEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("error reading replay file '%s' at line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading replay file '%s': %w", path, err)
+	}
+	return entries, nil
+}
+
+// writeReplay replaces the recordings of the tests in f that were run with what they recorded, keeping the rest.
+func writeReplay(f *testFile, results []testResult) error {
+	if f.Err != nil {
+		return nil
+	}
+	replay := f.Replay
+	ran := false
+	for _, r := range results {
+		if r.File == f.Path {
+			replay[r.Name] = r.Recorded
+			ran = true
+		}
+	}
+	if !ran {
+		return nil
+	}
+	var entries []replayEntry
+	for _, name := range f.Tests {
+		for _, ex := range replay[name] {
+			entries = append(entries, replayEntry{Test: name, Exchange: ex})
+		}
+	}
+	return writeReplayEntries(replayPath(f.Path), entries)
+}
+
+// writeReplayEntries writes entries to the replay file at path, removing the file if there are none.
+func writeReplayEntries(path string, entries []replayEntry) error {
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing replay file '%s': %w", path, err)
+	}
+	return nil
+}
+
+// runTests runs every test in files whose name matches filter, with up to parallel at once.
+// done is called with each result as it finishes, one at a time. The results are returned in the order of the files and tests.
+func runTests(files []*testFile, filter *regexp.Regexp, parallel int, opts testOptions, done func(testResult)) []testResult {
+	type job struct {
+		file *testFile
+		name string
+	}
+	var jobs []job
+	for _, f := range files {
+		if f.Err != nil {
+			jobs = append(jobs, job{file: f})
+		}
+		for _, name := range f.Tests {
+			if filter == nil || filter.MatchString(name) {
+				jobs = append(jobs, job{file: f, name: name})
+			}
+		}
+	}
+
+	results := make([]testResult, len(jobs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i, j := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			var r testResult
+			if j.file.Err != nil {
+				r = testResult{File: j.file.Path, Err: j.file.Err}
+			} else {
+				r = runTest(j.file, j.name, opts)
+			}
+			results[i] = r
+			mu.Lock()
+			done(r)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runTest runs a single test in a runtime of its own, with empty stdin and output captured.
+func runTest(f *testFile, name string, opts testOptions) testResult {
+	result := testResult{File: f.Path, Name: name}
+	var model jpf.Model
+	var recorder *backend.Recorder
+	if opts.live {
+		raw, err := backend.BuildRaw(opts.profile, nil)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		model = raw
+		if opts.record {
+			recorder = backend.NewRecorder(raw)
+			model = recorder
+		}
+	} else {
+		model = backend.NewReplayModel(f.Replay[name])
+	}
+
+	out := &bytes.Buffer{}
+	builder := hellm.BuildRuntime(model).
+		WithStdin(strings.NewReader("")).
+		WithStdout(out).
+		WithStderr(out).
+		WithPrompts(opts.prompts).
+		WithFSRoot(filepath.Dir(f.Path), false)
+	if opts.live {
+		builder = builder.WithLimits(opts.profile.Price, opts.profile.Limits)
+	}
+	if opts.narrow {
+		builder = builder.WithNarrow()
+	}
+	rt, err := builder.Validate()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	start := time.Now()
+	result.Err = rt.Test(f.Path, f.Code, name)
+	result.Duration = time.Since(start)
+	result.Output = out.String()
+	if recorder != nil {
+		result.Recorded = recorder.Exchanges()
+	}
+	return result
+}
+
+func printTestResult(r testResult, verbose bool) {
+	status := "PASS"
+	if r.Err != nil {
+		status = "FAIL"
+	}
+	if r.Name == "" {
+		fmt.Printf("--- %s: %s\n", status, r.File)
+	} else {
+		fmt.Printf("--- %s: %s: %q (%.2fs)\n", status, r.File, r.Name, r.Duration.Seconds())
+	}
+	if r.Err != nil {
+		fmt.Print(indentLines(r.Err.Error()+"\n"+stackTrace(r.Err), "    "))
+	}
+	if r.Output != "" && (verbose || r.Err != nil) {
+		fmt.Println("    output:")
+		fmt.Print(indentLines(r.Output, "      "))
+	}
+}
+
+// stackTrace is the hellm stack trace of err, if it has one.
+func stackTrace(err error) string {
+	var rerr *interpreter.RuntimeError
+	if errors.As(err, &rerr) {
+		return rerr.StackTrace()
+	}
+	return ""
+}
+
+func indentLines(text, indent string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	return indent + strings.Join(lines, "\n"+indent) + "\n"
+}
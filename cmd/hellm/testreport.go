@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+// writeReport creates the file at path and writes a report of results to it with write.
+func writeReport(path string, results []testResult, duration time.Duration, write func(io.Writer, []testResult, time.Duration) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating report '%s': %w", path, err)
+	}
+	defer f.Close()
+	if err := write(f, results, duration); err != nil {
+		return fmt.Errorf("error writing report '%s': %w", path, err)
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes results as JUnit XML, with a test suite for each file.
+func writeJUnit(w io.Writer, results []testResult, duration time.Duration) error {
+	report := junitTestSuites{Time: junitTime(duration)}
+	suites := map[string]int{}
+	for _, r := range results {
+		i, ok := suites[r.File]
+		if !ok {
+			i = len(report.Suites)
+			suites[r.File] = i
+			report.Suites = append(report.Suites, junitTestSuite{Name: r.File})
+		}
+		suite := &report.Suites[i]
+		name := r.Name
+		if name == "" {
+			name = "(load)"
+		}
+		tc := junitTestCase{Name: name, Classname: r.File, Time: junitTime(r.Duration), SystemOut: r.Output}
+		if r.Err != nil {
+			tc.Failure = &junitFailure{Message: r.Err.Error(), Type: interpreter.ErrorKind(r.Err), Text: stackTrace(r.Err)}
+			suite.Failures++
+			report.Failures++
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		report.Tests++
+	}
+	for i := range report.Suites {
+		var total time.Duration
+		for _, r := range results {
+			if r.File == report.Suites[i].Name {
+				total += r.Duration
+			}
+		}
+		report.Suites[i].Time = junitTime(total)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+type jsonReport struct {
+	Passed     int              `json:"passed"`
+	Failed     int              `json:"failed"`
+	DurationMS float64          `json:"duration_ms"`
+	Tests      []jsonTestResult `json:"tests"`
+}
+
+type jsonTestResult struct {
+	File       string  `json:"file"`
+	Name       string  `json:"name,omitempty"`
+	Passed     bool    `json:"passed"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+	Kind       string  `json:"kind,omitempty"`
+	Stack      string  `json:"stack,omitempty"`
+	Output     string  `json:"output,omitempty"`
+}
+
+// writeJSONReport writes results as a single JSON object.
+func writeJSONReport(w io.Writer, results []testResult, duration time.Duration) error {
+	report := jsonReport{DurationMS: msSince(duration), Tests: []jsonTestResult{}}
+	for _, r := range results {
+		tr := jsonTestResult{
+			File:       r.File,
+			Name:       r.Name,
+			Passed:     r.Err == nil,
+			DurationMS: msSince(r.Duration),
+			Output:     r.Output,
+		}
+		if r.Err != nil {
+			tr.Error, tr.Kind, tr.Stack = r.Err.Error(), interpreter.ErrorKind(r.Err), stackTrace(r.Err)
+			report.Failed++
+		} else {
+			report.Passed++
+		}
+		report.Tests = append(report.Tests, tr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(report)
+}
+
+func msSince(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/lexer"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	thrown := &interpreter.RuntimeError{
+		Err: &interpreter.ThrownError{Message: "bad <input>"},
+		Stack: []interpreter.StackFrame{
+			{Function: "check", Path: "a_test.hl", Pos: lexer.Pos{Line: 3, Col: 5}, Statement: `throw "bad <input>";`},
+		},
+	}
+	results := []testResult{
+		{File: "a_test.hl", Name: "passes", Duration: 1500 * time.Millisecond, Output: "hello\n"},
+		{File: "a_test.hl", Name: "throws", Duration: 250 * time.Millisecond, Err: thrown},
+		{File: "b_test.hl", Duration: 10 * time.Millisecond, Err: errors.New("could not parse")},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeJUnit(buf, results, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="2" time="2.000">
+  <testsuite name="a_test.hl" tests="2" failures="1" time="1.750">
+    <testcase name="passes" classname="a_test.hl" time="1.500">
+      <system-out>hello&#xA;</system-out>
+    </testcase>
+    <testcase name="throws" classname="a_test.hl" time="0.250">
+      <failure message="bad &lt;input&gt;" type="thrown">  at check (a_test.hl:3:5) throw &#34;bad &lt;input&gt;&#34;;&#xA;</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b_test.hl" tests="1" failures="1" time="0.010">
+    <testcase name="(load)" classname="b_test.hl" time="0.010">
+      <failure message="could not parse" type="error"></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if buf.String() != expected {
+		t.Errorf("expected JUnit XML:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+//go:embed trace.html
+var timelineTemplate string
+
+// traceNode is an event in a trace, along with the events that happened inside it.
+// Statements, function calls and model calls span from their start event to their end event. Anything else is a single event.
+type traceNode struct {
+	ID       int
+	Enter    interpreter.Event
+	Exit     interpreter.Event
+	Start    time.Time
+	End      time.Time
+	Depth    int
+	Finished bool
+	Children []*traceNode
+}
+
+// spanEnds maps the kinds of event that end a span to the kind that starts it.
+var spanEnds = map[interpreter.EventKind]interpreter.EventKind{
+	interpreter.StatementExit:  interpreter.StatementEnter,
+	interpreter.FunctionReturn: interpreter.FunctionCall,
+	interpreter.LLMResponse:    interpreter.LLMRequest,
+}
+
+func startsSpan(kind interpreter.EventKind) bool {
+	for _, start := range spanEnds {
+		if start == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTraceTree nests the events of a trace into a tree, returning its roots.
+// Spans that never ended (because the trace was cut short) end at the last event.
+func buildTraceTree(events []interpreter.Event) []*traceNode {
+	root := &traceNode{}
+	stack := []*traceNode{root}
+	nextID := 1
+	for _, ev := range events {
+		if start, ok := spanEnds[ev.Kind]; ok {
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].Enter.Kind == start {
+					stack[i].Exit, stack[i].End, stack[i].Finished = ev, ev.Time, true
+					stack = stack[:i]
+					break
+				}
+			}
+			continue
+		}
+		top := stack[len(stack)-1]
+		n := &traceNode{ID: nextID, Enter: ev, Start: ev.Time, End: ev.Time, Depth: len(stack) - 1}
+		nextID++
+		top.Children = append(top.Children, n)
+		if startsSpan(ev.Kind) {
+			stack = append(stack, n)
+		} else {
+			n.Finished = true
+		}
+	}
+	if len(events) > 0 {
+		for _, n := range stack[1:] {
+			n.End = events[len(events)-1].Time
+		}
+	}
+	return root.Children
+}
+
+// walkTrace calls fn with every node in the tree, parents before their children.
+func walkTrace(nodes []*traceNode, fn func(*traceNode)) {
+	for _, n := range nodes {
+		fn(n)
+		walkTrace(n.Children, fn)
+	}
+}
+
+func (n *traceNode) Duration() time.Duration {
+	return n.End.Sub(n.Start)
+}
+
+// Usage is the tokens and cost of every model call in or under the node.
+func (n *traceNode) Usage() (inputTokens, outputTokens int, cost float64) {
+	inputTokens, outputTokens, cost = n.Exit.InputTokens, n.Exit.OutputTokens, n.Exit.Cost
+	for _, child := range n.Children {
+		in, out, c := child.Usage()
+		inputTokens, outputTokens, cost = inputTokens+in, outputTokens+out, cost+c
+	}
+	return inputTokens, outputTokens, cost
+}
+
+func (n *traceNode) Error() string {
+	if n.Exit.Error != "" {
+		return n.Exit.Error
+	}
+	return n.Enter.Error
+}
+
+// Label is a one line description of the node.
+func (n *traceNode) Label() string {
+	ev := n.Enter
+	switch ev.Kind {
+	case interpreter.StatementEnter:
+		return fmt.Sprintf("%d:%d %s", ev.Line, ev.Col, ev.Statement)
+	case interpreter.FunctionCall:
+		names := slices.Sorted(maps.Keys(ev.Args))
+		args := make([]string, len(names))
+		for i, name := range names {
+			args[i] = name + "=" + shorten(ev.Args[name], 30)
+		}
+		return fmt.Sprintf("call %s(%s)", ev.Function, strings.Join(args, ", "))
+	case interpreter.LLMRequest:
+		return "llm " + shorten(ev.Text, 50)
+	case interpreter.Branch:
+		if ev.Taken != nil && *ev.Taken {
+			return "branch taken"
+		}
+		return "branch not taken"
+	case interpreter.LoopIteration:
+		return fmt.Sprintf("iteration %d", ev.Iteration)
+	case interpreter.Print:
+		return ev.Stream + " " + shorten(ev.Text, 50)
+	case interpreter.FileAccess:
+		label := fmt.Sprintf("%s %s (%d bytes)", ev.Op, ev.Path, ev.Bytes)
+		if ev.DryRun {
+			label += " (dry run)"
+		}
+		return label
+	case interpreter.ErrorCaught:
+		return "caught: " + ev.Error
+	case interpreter.RunError:
+		return "error: " + ev.Error
+	default:
+		return string(ev.Kind)
+	}
+}
+
+// Stats describes the latency, tokens and cost of the node, and whether it failed.
+func (n *traceNode) Stats() string {
+	var stats []string
+	if startsSpan(n.Enter.Kind) {
+		stats = append(stats, formatDuration(n.Duration()))
+		if !n.Finished {
+			stats = append(stats, "unfinished")
+		}
+	}
+	in, out, cost := n.Usage()
+	if in+out > 0 {
+		stats = append(stats, fmt.Sprintf("%d tokens (%d in, %d out)", in+out, in, out))
+	}
+	if cost > 0 {
+		stats = append(stats, fmt.Sprintf("$%.6f", cost))
+	}
+	if err := n.Error(); err != "" && n.Enter.Kind != interpreter.RunError && n.Enter.Kind != interpreter.ErrorCaught {
+		stats = append(stats, "failed: "+err)
+	}
+	return strings.Join(stats, "  ")
+}
+
+// writeDetails writes everything the node recorded that doesn't fit in its label, with each line prefixed by indent.
+func (n *traceNode) writeDetails(w io.Writer, indent string) {
+	section := func(name, text string) {
+		fmt.Fprintf(w, "%s%s:\n", indent, name)
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(w, "%s  %s\n", indent, line)
+		}
+	}
+	switch n.Enter.Kind {
+	case interpreter.LLMRequest:
+		section("prompt", n.Enter.Prompt)
+		section("text", n.Enter.Text)
+		if len(n.Enter.Variables) > 0 {
+			fmt.Fprintf(w, "%svariables:\n", indent)
+			for _, v := range n.Enter.Variables {
+				fmt.Fprintf(w, "%s  %s = %s\n", indent, v.Name, strconv.Quote(v.Value))
+			}
+		}
+		if n.Finished {
+			section("response", n.Exit.Response)
+		}
+	case interpreter.FunctionCall:
+		for _, name := range slices.Sorted(maps.Keys(n.Enter.Args)) {
+			fmt.Fprintf(w, "%sarg %s = %s\n", indent, name, strconv.Quote(n.Enter.Args[name]))
+		}
+		for i, ret := range n.Exit.Returns {
+			fmt.Fprintf(w, "%sreturn %d = %s\n", indent, i+1, strconv.Quote(ret))
+		}
+	}
+	if err := n.Error(); err != "" {
+		section("error", err)
+	}
+}
+
+// writeTraceTree writes the tree as indented lines, with the details of the nodes that expand says to expand.
+func writeTraceTree(w io.Writer, nodes []*traceNode, expand func(id int) bool) {
+	walkTrace(nodes, func(n *traceNode) {
+		indent := strings.Repeat("  ", n.Depth)
+		fmt.Fprintf(w, "%s#%d %s", indent, n.ID, n.Label())
+		if stats := n.Stats(); stats != "" {
+			fmt.Fprintf(w, "  [%s]", stats)
+		}
+		fmt.Fprintln(w)
+		if expand(n.ID) {
+			n.writeDetails(w, indent+"  | ")
+		}
+	})
+}
+
+// traceSpan is when the first node in the tree started and the last one ended.
+func traceSpan(nodes []*traceNode) (start, end time.Time) {
+	walkTrace(nodes, func(n *traceNode) {
+		if start.IsZero() || n.Start.Before(start) {
+			start = n.Start
+		}
+		if n.End.After(end) {
+			end = n.End
+		}
+	})
+	return start, end
+}
+
+// traceSummary totals up a whole trace.
+func traceSummary(nodes []*traceNode) string {
+	var statements, calls, llmCalls, in, out int
+	var cost float64
+	walkTrace(nodes, func(n *traceNode) {
+		switch n.Enter.Kind {
+		case interpreter.StatementEnter:
+			statements++
+		case interpreter.FunctionCall:
+			calls++
+		case interpreter.LLMRequest:
+			llmCalls++
+		}
+	})
+	for _, n := range nodes {
+		i, o, c := n.Usage()
+		in, out, cost = in+i, out+o, cost+c
+	}
+	start, end := traceSpan(nodes)
+	return fmt.Sprintf(
+		"%s total, %d statements, %d function calls, %d llm calls, %d tokens (%d in, %d out), $%.6f",
+		formatDuration(end.Sub(start)), statements, calls, llmCalls, in+out, in, out, cost,
+	)
+}
+
+const timelineRowHeight = 24
+
+// timelineBar is a node drawn in the HTML timeline.
+type timelineBar struct {
+	ID      int
+	Kind    string
+	Label   string
+	Stats   string
+	Details string
+	Failed  bool
+	// Top is in pixels, and Left and Width are percentages of the whole trace.
+	Top   int
+	Left  float64
+	Width float64
+}
+
+// writeTimeline writes a self-contained HTML page that draws the tree as a flamegraph-style timeline.
+func writeTimeline(w io.Writer, title string, nodes []*traceNode) error {
+	tmpl, err := template.New("timeline").Parse(timelineTemplate)
+	if err != nil {
+		return err
+	}
+	start, end := traceSpan(nodes)
+	total := max(end.Sub(start), time.Nanosecond)
+	var bars []timelineBar
+	maxDepth := 0
+	walkTrace(nodes, func(n *traceNode) {
+		details := &strings.Builder{}
+		n.writeDetails(details, "")
+		bars = append(bars, timelineBar{
+			ID:      n.ID,
+			Kind:    string(n.Enter.Kind),
+			Label:   n.Label(),
+			Stats:   n.Stats(),
+			Details: details.String(),
+			Failed:  n.Error() != "" && n.Enter.Kind != interpreter.ErrorCaught,
+			Top:     n.Depth * timelineRowHeight,
+			Left:    100 * float64(n.Start.Sub(start)) / float64(total),
+			Width:   max(100*float64(n.Duration())/float64(total), 0.1),
+		})
+		maxDepth = max(maxDepth, n.Depth)
+	})
+	return tmpl.Execute(w, map[string]any{
+		"Title":   title,
+		"Summary": traceSummary(nodes),
+		"Bars":    bars,
+		"Height":  (maxDepth + 1) * timelineRowHeight,
+	})
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+// shorten quotes s, cutting it down to at most n runes and keeping it on one line.
+func shorten(s string, n int) string {
+	return strconv.Quote(truncate(s, n))
+}
+
+// truncate collapses the whitespace in s, cutting it to at most n runes.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > n {
+		s = string(runes[:n-3]) + "..."
+	}
+	return s
+}
+
+func cmdTrace(args []string) error {
+	if len(args) < 1 || args[0] != "show" {
+		return fmt.Errorf("usage: hellm trace show [--expand all|<ids>] [--html <file>] <trace file>")
+	}
+	fs := flag.NewFlagSet("trace show", flag.ExitOnError)
+	expandFlag := fs.String("expand", "", "nodes to show the prompt, response and arguments of: all, or a comma separated list of ids")
+	htmlPath := fs.String("html", "", "file to write an HTML timeline of the trace to, instead of printing it")
+	fs.Parse(args[1:])
+	args = fs.Args()
+
+	if len(args) < 1 {
+		return fmt.Errorf("must provide a trace file to show")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error opening trace file '%s': %w", args[0], err)
+	}
+	defer f.Close()
+	events, err := interpreter.ReadTrace(f)
+	if err != nil {
+		return err
+	}
+	nodes := buildTraceTree(events)
+
+	if *htmlPath != "" {
+		out, err := os.Create(*htmlPath)
+		if err != nil {
+			return fmt.Errorf("error creating timeline file '%s': %w", *htmlPath, err)
+		}
+		defer out.Close()
+		return writeTimeline(out, args[0], nodes)
+	}
+
+	expand, err := parseExpand(*expandFlag)
+	if err != nil {
+		return err
+	}
+	writeTraceTree(os.Stdout, nodes, expand)
+	fmt.Println(traceSummary(nodes))
+	return nil
+}
+
+// parseExpand parses the --expand flag into a function that says whether to expand the node with an id.
+func parseExpand(s string) (func(id int) bool, error) {
+	if s == "all" {
+		return func(int) bool { return true }, nil
+	}
+	ids := map[int]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "#")
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("--expand must be all or a comma separated list of ids, got '%s'", s)
+		}
+		ids[id] = true
+	}
+	return func(id int) bool { return ids[id] }, nil
+}
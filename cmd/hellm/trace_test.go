@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+// fixedTrace is a statement that calls a function, which asks the model something with HTML in its prompt, then prints.
+func fixedTrace() []interpreter.Event {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	return []interpreter.Event{
+		{Kind: interpreter.StatementEnter, Time: at(0), Line: 1, Col: 1, Statement: "run s = summarise doc;"},
+		{Kind: interpreter.FunctionCall, Time: at(1), Function: "summarise", Args: map[string]string{"text": "<b>hi</b>"}},
+		{Kind: interpreter.StatementEnter, Time: at(2), Line: 2, Col: 5, Statement: `let s = "Summarise {text}";`},
+		{Kind: interpreter.LLMRequest, Time: at(3), Prompt: "<script>alert(1)</script>", Text: "Summarise <b>hi</b> & more"},
+		{Kind: interpreter.LLMResponse, Time: at(253), Response: "ok", InputTokens: 10, OutputTokens: 2, Cost: 0.0001},
+		{Kind: interpreter.StatementExit, Time: at(254)},
+		{Kind: interpreter.FunctionReturn, Time: at(255), Returns: []string{"ok"}},
+		{Kind: interpreter.StatementExit, Time: at(260)},
+		{Kind: interpreter.Print, Time: at(261), Stream: "stdout", Text: "ok"},
+	}
+}
+
+func TestWriteTraceTree(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeTraceTree(buf, buildTraceTree(fixedTrace()), func(id int) bool { return id == 4 })
+	expected := `#1 1:1 run s = summarise doc;  [260ms  12 tokens (10 in, 2 out)  $0.000100]
+  #2 call summarise(text="<b>hi</b>")  [254ms  12 tokens (10 in, 2 out)  $0.000100]
+    #3 2:5 let s = "Summarise {text}";  [252ms  12 tokens (10 in, 2 out)  $0.000100]
+      #4 llm "Summarise <b>hi</b> & more"  [250ms  12 tokens (10 in, 2 out)  $0.000100]
+        | prompt:
+        |   <script>alert(1)</script>
+        | text:
+        |   Summarise <b>hi</b> & more
+        | response:
+        |   ok
+#5 stdout "ok"
+`
+	if buf.String() != expected {
+		t.Errorf("expected tree\n%s\ngot\n%s", expected, buf.String())
+	}
+	summary := traceSummary(buildTraceTree(fixedTrace()))
+	if want := "261ms total, 2 statements, 1 function calls, 1 llm calls, 12 tokens (10 in, 2 out), $0.000100"; summary != want {
+		t.Errorf("expected summary %q, got %q", want, summary)
+	}
+}
+
+func TestWriteTraceTreeUnfinished(t *testing.T) {
+	events := fixedTrace()[:4]
+	buf := &bytes.Buffer{}
+	writeTraceTree(buf, buildTraceTree(events), func(int) bool { return false })
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.Contains(line, "unfinished") {
+			t.Errorf("expected every span of a cut short trace to be unfinished, got %q", line)
+		}
+	}
+}
+
+func TestWriteTimeline(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeTimeline(buf, "run <1>", buildTraceTree(fixedTrace())); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, leak := range []string{"<script>alert", "<b>hi", "run <1>"} {
+		if strings.Contains(page, leak) {
+			t.Errorf("expected %q to be escaped in the timeline", leak)
+		}
+	}
+	for _, want := range []string{
+		"<title>hellm trace: run &lt;1&gt;</title>",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"Summarise &lt;b&gt;hi&lt;/b&gt; &amp; more",
+		`class="bar statement_enter" id="bar-1" data-id="1"`,
+		"left: 0.0000%; width: 99.6169%; top: 0px",
+		"left: 1.1494%; width: 95.7854%; top: 72px",
+		`class="bar print" id="bar-5" data-id="5"`,
+		"left: 100.0000%; width: 0.1000%; top: 0px",
+		"height: 96px",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the timeline to contain %q", want)
+		}
+	}
+}
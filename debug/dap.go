@@ -0,0 +1,449 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/textproto"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+// threadID is the id of the only thread a hellm program has.
+const threadID = 1
+
+// LaunchRequest is the program an editor asked to debug, from the arguments of its launch request.
+type LaunchRequest struct {
+	Program     string   `json:"program"`
+	Args        []string `json:"args"`
+	Profile     string   `json:"profile"`
+	FSRoot      string   `json:"fsRoot"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+	NoDebug     bool     `json:"noDebug"`
+}
+
+// LaunchFunc gets the program in req ready to run, returning a function that runs it.
+// The program should print to stdout and stderr, and be paused by debugger, which is nil if req.NoDebug is set.
+type LaunchFunc func(req LaunchRequest, debugger interpreter.Debugger, stdout, stderr io.Writer) (run func() error, err error)
+
+// Server is a Debug Adapter Protocol server for debugging a single hellm program.
+type Server struct {
+	in      *textproto.Reader
+	launch  LaunchFunc
+	session *Session
+	run     func() error
+
+	mu  sync.Mutex
+	out io.Writer
+	seq int
+	// refs are the scopes that variables requests can ask for, by variablesReference minus one. They are reset each time the program pauses.
+	refs []scopeRef
+}
+
+// scopeRef is a level of variables, or the function table, of a frame of the paused program.
+type scopeRef struct {
+	frame int
+	// level is the index of the variable level in the frame's scope, or -1 for its functions.
+	level int
+}
+
+// NewServer creates a server that reads requests from r and writes responses and events to w, calling launch to start the program.
+func NewServer(r io.Reader, w io.Writer, launch LaunchFunc) *Server {
+	s := &Server{
+		in:     textproto.NewReader(bufio.NewReader(r)),
+		out:    w,
+		launch: launch,
+	}
+	s.session = NewSession(s.stopped)
+	return s
+}
+
+type request struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Command    string `json:"command"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+// Serve handles requests until the editor disconnects or r is closed.
+func (s *Server) Serve() error {
+	for {
+		req, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		body, err := s.handle(req)
+		resp := response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		if err := s.send(&resp); err != nil {
+			return err
+		}
+		switch {
+		case req.Command == "initialize":
+			s.event("initialized", nil)
+		case req.Command == "configurationDone" && err == nil:
+			go s.runProgram()
+		case req.Command == "disconnect":
+			return nil
+		}
+	}
+}
+
+func (s *Server) read() (request, error) {
+	headers, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		return request{}, err
+	}
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return request{}, fmt.Errorf("invalid Content-Length header: %w", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in.R, data); err != nil {
+		return request{}, err
+	}
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return request{}, fmt.Errorf("invalid request: %w", err)
+	}
+	return req, nil
+}
+
+// send writes a response or event, filling in its sequence number.
+func (s *Server) send(msg any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *Server) event(name string, body any) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) handle(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsSetVariable":              true,
+			"supportsTerminateRequest":         true,
+			"supportsEvaluateForHovers":        true,
+		}, nil
+	case "launch":
+		var args LaunchRequest
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launchProgram(args)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "configurationDone":
+		if s.run == nil {
+			return nil, fmt.Errorf("no program has been launched")
+		}
+		return nil, nil
+	case "threads":
+		return map[string]any{"threads": []map[string]any{{"id": threadID, "name": "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "setVariable":
+		return s.setVariable(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		s.session.Continue()
+		return map[string]any{"allThreadsContinued": true}, nil
+	case "next":
+		s.session.StepOver()
+		return nil, nil
+	case "stepIn":
+		s.session.StepIn()
+		return nil, nil
+	case "stepOut":
+		s.session.StepOut()
+		return nil, nil
+	case "pause":
+		s.session.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.session.Terminate()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported request '%s'", req.Command)
+	}
+}
+
+func (s *Server) launchProgram(args LaunchRequest) error {
+	if args.Program == "" {
+		return fmt.Errorf("launch requires a program")
+	}
+	var debugger interpreter.Debugger = s.session
+	if args.NoDebug {
+		debugger = nil
+	} else if args.StopOnEntry {
+		s.session.StopOnEntry()
+	}
+	run, err := s.launch(args, debugger, &outputWriter{s, "stdout"}, &outputWriter{s, "stderr"})
+	if err != nil {
+		return err
+	}
+	s.run = run
+	return nil
+}
+
+func (s *Server) runProgram() {
+	exitCode := 0
+	if err := s.run(); err != nil {
+		exitCode = 1
+		s.event("output", map[string]any{"category": "stderr", "output": "fatal error: " + err.Error() + "\n"})
+	}
+	s.event("exited", map[string]any{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// stopped is called by the session when it pauses the program.
+func (s *Server) stopped(reason StopReason) {
+	s.mu.Lock()
+	s.refs = nil
+	s.mu.Unlock()
+	s.event("stopped", map[string]any{"reason": string(reason), "threadId": threadID, "allThreadsStopped": true})
+}
+
+// outputWriter sends what is written to it to the editor as output events.
+type outputWriter struct {
+	s        *Server
+	category string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]any{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+func (s *Server) setBreakpoints(raw json.RawMessage) (any, error) {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	lines := make([]int, len(args.Breakpoints))
+	verified := make([]map[string]any, len(args.Breakpoints))
+	for i, bp := range args.Breakpoints {
+		lines[i] = bp.Line
+		verified[i] = map[string]any{"verified": true, "line": bp.Line}
+	}
+	s.session.SetBreakpoints(args.Source.Path, lines)
+	return map[string]any{"breakpoints": verified}, nil
+}
+
+// frame returns the frame of the paused program with the given id, where frame 0 is the innermost.
+func (s *Server) frame(id int) (interpreter.Frame, error) {
+	stack := s.session.Stack()
+	if id < 0 || id >= len(stack) {
+		return interpreter.Frame{}, fmt.Errorf("no frame with id %d, the program may be running", id)
+	}
+	return stack[len(stack)-1-id], nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	stack := s.session.Stack()
+	frames := make([]map[string]any, 0, len(stack))
+	for id := range stack {
+		f := stack[len(stack)-1-id]
+		name := f.Function
+		if name == "" {
+			name = "<top level>"
+		}
+		pos := f.Statement.Position()
+		frame := map[string]any{"id": id, "name": name, "line": pos.Line, "column": pos.Col}
+		if f.Path != "" {
+			frame["source"] = map[string]any{"name": filepath.Base(f.Path), "path": f.Path}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *Server) scopes(raw json.RawMessage) (any, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	levels := f.Scope.Levels()
+	scopes := make([]map[string]any, 0, len(levels)+1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Innermost level first, as that is where the statement's own variables are
+	for level := len(levels) - 1; level >= 0; level-- {
+		name := fmt.Sprintf("Level %d", level)
+		if level == len(levels)-1 {
+			name = "Locals"
+		} else if level == 0 {
+			name = "Globals"
+		}
+		s.refs = append(s.refs, scopeRef{frame: args.FrameID, level: level})
+		scopes = append(scopes, map[string]any{"name": name, "variablesReference": len(s.refs), "expensive": false})
+	}
+	s.refs = append(s.refs, scopeRef{frame: args.FrameID, level: -1})
+	scopes = append(scopes, map[string]any{"name": "Functions", "variablesReference": len(s.refs), "expensive": false})
+	return map[string]any{"scopes": scopes}, nil
+}
+
+// ref looks up the scope with the given variablesReference.
+func (s *Server) ref(id int) (scopeRef, interpreter.Frame, error) {
+	s.mu.Lock()
+	if id < 1 || id > len(s.refs) {
+		s.mu.Unlock()
+		return scopeRef{}, interpreter.Frame{}, fmt.Errorf("no variables with reference %d", id)
+	}
+	ref := s.refs[id-1]
+	s.mu.Unlock()
+	f, err := s.frame(ref.frame)
+	return ref, f, err
+}
+
+func (s *Server) variables(raw json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	ref, f, err := s.ref(args.VariablesReference)
+	if err != nil {
+		return nil, err
+	}
+	vars := []map[string]any{}
+	if ref.level < 0 {
+		funcs := f.Scope.Funcs()
+		for _, name := range slices.Sorted(maps.Keys(funcs)) {
+			fn := funcs[name]
+			value := fmt.Sprintf("fn(%s)", strings.Join(fn.Def.Args, ", "))
+			if fn.Path != "" {
+				value += " in " + filepath.Base(fn.Path)
+			}
+			vars = append(vars, map[string]any{"name": name, "value": value, "type": "function", "variablesReference": 0})
+		}
+		return map[string]any{"variables": vars}, nil
+	}
+	levels := f.Scope.Levels()
+	if ref.level >= len(levels) {
+		return map[string]any{"variables": vars}, nil
+	}
+	level := levels[ref.level]
+	for _, name := range slices.Sorted(maps.Keys(level.Variables)) {
+		typ := "string"
+		if level.Hidden[name] {
+			typ = "hidden"
+		}
+		vars = append(vars, map[string]any{"name": name, "value": level.Variables[name], "type": typ, "variablesReference": 0})
+	}
+	return map[string]any{"variables": vars}, nil
+}
+
+func (s *Server) setVariable(raw json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	ref, f, err := s.ref(args.VariablesReference)
+	if err != nil {
+		return nil, err
+	}
+	if ref.level < 0 {
+		return nil, fmt.Errorf("functions cannot be changed")
+	}
+	if !f.Scope.Has(args.Name) {
+		return nil, fmt.Errorf("variable %s is not in scope", args.Name)
+	}
+	// The editor may send the value quoted, as it is shown in a string literal
+	value := args.Value
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	if f.Scope.IsHidden(args.Name) {
+		f.Scope.SetHidden(args.Name, value)
+	} else {
+		f.Scope.Set(args.Name, value)
+	}
+	return map[string]any{"value": value}, nil
+}
+
+func (s *Server) evaluate(raw json.RawMessage) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	val, err := f.Scope.Get(strings.TrimSpace(args.Expression))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"result": val, "variablesReference": 0}, nil
+}
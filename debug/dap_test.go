@@ -0,0 +1,286 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// message is a response or event from the server, with the fields the tests look at.
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	Event      string          `json:"event"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// dapClient talks to a server over pipes, like an editor would.
+type dapClient struct {
+	t        *testing.T
+	w        io.Writer
+	seq      int
+	messages chan message
+	// skipped are messages that have arrived but not been waited for yet, as events can come before the response to the request that caused them.
+	skipped []message
+}
+
+func newDAPClient(t *testing.T, launch LaunchFunc) *dapClient {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	server := NewServer(reqR, respW, launch)
+	go func() {
+		server.Serve()
+		respW.Close()
+	}()
+	c := &dapClient{t: t, w: reqW, messages: make(chan message, 100)}
+	go func() {
+		defer close(c.messages)
+		in := textproto.NewReader(bufio.NewReader(respR))
+		for {
+			headers, err := in.ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			length, _ := strconv.Atoi(headers.Get("Content-Length"))
+			data := make([]byte, length)
+			if _, err := io.ReadFull(in.R, data); err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Errorf("invalid message from server: %s", data)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() { reqW.Close() })
+	return c
+}
+
+// request sends a request and returns its response, failing the test if it was not successful.
+func (c *dapClient) request(command string, args any) json.RawMessage {
+	c.t.Helper()
+	resp := c.send(command, args)
+	if !resp.Success {
+		c.t.Fatalf("%s request failed: %s", command, resp.Message)
+	}
+	return resp.Body
+}
+
+// send sends a request and returns its response.
+func (c *dapClient) send(command string, args any) message {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	data, err := json.Marshal(map[string]any{"seq": seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.wait(func(msg message) bool { return msg.Type == "response" && msg.RequestSeq == seq })
+}
+
+// event waits for the event called name, returning its body.
+func (c *dapClient) event(name string) json.RawMessage {
+	c.t.Helper()
+	return c.wait(func(msg message) bool { return msg.Type == "event" && msg.Event == name }).Body
+}
+
+// wait returns the first message that matches, waiting for it if it has not arrived yet.
+func (c *dapClient) wait(match func(message) bool) message {
+	c.t.Helper()
+	for i, msg := range c.skipped {
+		if match(msg) {
+			c.skipped = slices.Delete(c.skipped, i, i+1)
+			return msg
+		}
+	}
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("server closed the connection")
+			}
+			if match(msg) {
+				return msg
+			}
+			c.skipped = append(c.skipped, msg)
+		case <-time.After(5 * time.Second):
+			c.t.Fatal("timed out waiting for a message from the server")
+		}
+	}
+}
+
+// launchHellm runs the program from the launch request with a replay model that lets loops run three times.
+func launchHellm(req LaunchRequest, debugger interpreter.Debugger, stdout, stderr io.Writer) (func() error, error) {
+	src, err := os.ReadFile(req.Program)
+	if err != nil {
+		return nil, err
+	}
+	code, err := parser.ParseSource(string(src))
+	if err != nil {
+		return nil, err
+	}
+	interp, err := interpreter.New(strings.NewReader(""), stdout, interpreter.Options{
+		Model:    backend.NewReplayModel(loopAnswers),
+		Debugger: debugger,
+		Stderr:   stderr,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return interp.Run(code, req.Program, nil) }, nil
+}
+
+func decode[T any](t *testing.T, data json.RawMessage) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatalf("invalid body %s: %v", data, err)
+	}
+	return v
+}
+
+type stackTraceBody struct {
+	StackFrames []struct {
+		ID     int    `json:"id"`
+		Name   string `json:"name"`
+		Line   int    `json:"line"`
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+	} `json:"stackFrames"`
+}
+
+type variablesBody struct {
+	Variables []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  string `json:"type"`
+	} `json:"variables"`
+}
+
+func TestDAP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.hl")
+	if err := os.WriteFile(path, []byte(loopProgram), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPClient(t, launchHellm)
+	c.request("initialize", map[string]any{"adapterID": "hellm"})
+	c.event("initialized")
+	c.request("launch", map[string]any{"program": path})
+	bps := decode[struct {
+		Breakpoints []struct {
+			Verified bool `json:"verified"`
+			Line     int  `json:"line"`
+		} `json:"breakpoints"`
+	}](t, c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": []map[string]any{{"line": 3}}}))
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified || bps.Breakpoints[0].Line != 3 {
+		t.Fatalf("expected a verified breakpoint on line 3, got %+v", bps)
+	}
+	c.request("configurationDone", nil)
+
+	stopped := decode[struct {
+		Reason   string `json:"reason"`
+		ThreadID int    `json:"threadId"`
+	}](t, c.event("stopped"))
+	if stopped.Reason != "breakpoint" || stopped.ThreadID != threadID {
+		t.Fatalf("expected to stop at a breakpoint, got %+v", stopped)
+	}
+	stack := decode[stackTraceBody](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	if len(stack.StackFrames) != 1 || stack.StackFrames[0].Line != 3 || stack.StackFrames[0].Source.Path != path || stack.StackFrames[0].Name != "<top level>" {
+		t.Fatalf("expected to be stopped on line 3 of %s, got %+v", path, stack)
+	}
+
+	scopes := decode[struct {
+		Scopes []struct {
+			Name               string `json:"name"`
+			VariablesReference int    `json:"variablesReference"`
+		} `json:"scopes"`
+	}](t, c.request("scopes", map[string]any{"frameId": 0}))
+	globals := 0
+	for _, scope := range scopes.Scopes {
+		if scope.Name == "Globals" {
+			globals = scope.VariablesReference
+		}
+	}
+	if globals == 0 {
+		t.Fatalf("expected a Globals scope, got %+v", scopes)
+	}
+	vars := decode[variablesBody](t, c.request("variables", map[string]any{"variablesReference": globals}))
+	if len(vars.Variables) != 1 || vars.Variables[0].Name != "n" || vars.Variables[0].Value != "0" {
+		t.Fatalf("expected n = 0 in the globals, got %+v", vars)
+	}
+	c.request("setVariable", map[string]any{"variablesReference": globals, "name": "n", "value": `"1"`})
+	eval := decode[struct {
+		Result string `json:"result"`
+	}](t, c.request("evaluate", map[string]any{"expression": "n", "frameId": 0}))
+	if eval.Result != "1" {
+		t.Errorf("expected n to have been set to 1, got %q", eval.Result)
+	}
+	if resp := c.send("setVariable", map[string]any{"variablesReference": globals, "name": "missing", "value": "x"}); resp.Success {
+		t.Errorf("expected setting a variable that is not in scope to fail")
+	}
+	if resp := c.send("evaluate", map[string]any{"expression": "n", "frameId": 5}); resp.Success {
+		t.Errorf("expected evaluating in a frame that does not exist to fail")
+	}
+
+	// The breakpoint is in a loop that runs three times
+	c.request("continue", map[string]any{"threadId": threadID})
+	c.event("stopped")
+	c.request("next", map[string]any{"threadId": threadID})
+	c.event("stopped")
+	stack = decode[stackTraceBody](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	if stack.StackFrames[0].Line != 3 {
+		t.Errorf("expected stepping over the loop body to stop on it again, got line %d", stack.StackFrames[0].Line)
+	}
+	c.request("setBreakpoints", map[string]any{"source": map[string]any{"path": path}, "breakpoints": []map[string]any{}})
+	c.request("continue", map[string]any{"threadId": threadID})
+	exited := decode[struct {
+		ExitCode int `json:"exitCode"`
+	}](t, c.event("exited"))
+	if exited.ExitCode != 0 {
+		t.Errorf("expected the program to exit cleanly, got %d", exited.ExitCode)
+	}
+	c.event("terminated")
+	c.request("disconnect", nil)
+}
+
+func TestDAPErrors(t *testing.T) {
+	c := newDAPClient(t, launchHellm)
+	c.request("initialize", nil)
+	cases := []struct {
+		command string
+		args    any
+	}{
+		{command: "launch", args: map[string]any{}},
+		{command: "launch", args: map[string]any{"program": filepath.Join(t.TempDir(), "missing.hl")}},
+		{command: "configurationDone"},
+		{command: "variables", args: map[string]any{"variablesReference": 1}},
+		{command: "made_up"},
+	}
+	for _, tc := range cases {
+		if resp := c.send(tc.command, tc.args); resp.Success {
+			t.Errorf("expected %s %v to fail", tc.command, tc.args)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package debug pauses and steps through hellm programs, and serves the Debug Adapter Protocol so that editors can do it too.
+package debug
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+
+	"github.com/JoshPattman/hellm/interpreter"
+)
+
+// ErrTerminated is returned from a program that was stopped by the debugger.
+var ErrTerminated = errors.New("program terminated by the debugger")
+
+// StopReason is why the program was paused.
+type StopReason string
+
+const (
+	Breakpoint StopReason = "breakpoint"
+	Step       StopReason = "step"
+	Pause      StopReason = "pause"
+	Entry      StopReason = "entry"
+)
+
+type stepMode int
+
+const (
+	noStep stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+// location is a line in a file at a depth of the call stack.
+type location struct {
+	path  string
+	line  int
+	depth int
+}
+
+// Session is a step debugger for a single run of a hellm program. It pauses the program at breakpoints, after steps and when asked to.
+// Statements are paused at the first one reached on a line, so a line with several statements on it is only stopped at once each time the program passes over it.
+// Going back to the same statement or an earlier one on the line, as a loop does, is a new pass.
+type Session struct {
+	mu          sync.Mutex
+	onStop      func(StopReason)
+	breakpoints map[string]map[int]bool
+	step        stepMode
+	stepDepth   int
+	pause       StopReason
+	last        location
+	// lastCol is the column of the last statement reached, on the line in last.
+	lastCol    int
+	terminated bool
+	// stack is the call stack while the program is paused, and nil while it is running.
+	stack  []interpreter.Frame
+	resume chan struct{}
+}
+
+// NewSession creates a debugger that calls onStop each time it pauses the program.
+// The program stays paused until one of Continue, StepIn, StepOver, StepOut or Terminate is called.
+func NewSession(onStop func(StopReason)) *Session {
+	return &Session{
+		onStop:      onStop,
+		breakpoints: map[string]map[int]bool{},
+		resume:      make(chan struct{}, 1),
+	}
+}
+
+// SetBreakpoints replaces the breakpoints in the file at path with ones on each of lines.
+func (s *Session) SetBreakpoints(path string, lines []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := map[int]bool{}
+	for _, line := range lines {
+		set[line] = true
+	}
+	s.breakpoints[cleanPath(path)] = set
+}
+
+// StopOnEntry pauses the program before its first statement.
+func (s *Session) StopOnEntry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pause = Entry
+}
+
+// Pause pauses the program before the next statement it runs.
+func (s *Session) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stack == nil {
+		s.pause = Pause
+	}
+}
+
+// Continue runs a paused program until the next breakpoint.
+func (s *Session) Continue() {
+	s.resumeWith(noStep)
+}
+
+// StepIn runs a paused program to the next line, following it into any function it calls.
+func (s *Session) StepIn() {
+	s.resumeWith(stepIn)
+}
+
+// StepOver runs a paused program to the next line in the same function, running any function it calls in full.
+func (s *Session) StepOver() {
+	s.resumeWith(stepOver)
+}
+
+// StepOut runs a paused program until the function it is in returns.
+func (s *Session) StepOut() {
+	s.resumeWith(stepOut)
+}
+
+// Terminate stops the program before the next statement it runs, making it fail with ErrTerminated.
+func (s *Session) Terminate() {
+	s.mu.Lock()
+	s.terminated = true
+	s.mu.Unlock()
+	s.resumeWith(noStep)
+}
+
+func (s *Session) resumeWith(mode stepMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stack == nil {
+		return
+	}
+	s.step, s.stepDepth = mode, len(s.stack)
+	s.stack = nil
+	s.resume <- struct{}{}
+}
+
+// Stack returns the call stack of the paused program, innermost frame last, or nil if it is running.
+// The scopes of the frames may be changed until the program is resumed.
+func (s *Session) Stack() []interpreter.Frame {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stack
+}
+
+// Break implements interpreter.Debugger.
+func (s *Session) Break(stack []interpreter.Frame) error {
+	s.mu.Lock()
+	if s.terminated {
+		s.mu.Unlock()
+		return ErrTerminated
+	}
+	reason, stop := s.shouldStop(stack)
+	if !stop {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stack, s.pause = stack, ""
+	s.mu.Unlock()
+
+	s.onStop(reason)
+	<-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.terminated {
+		return ErrTerminated
+	}
+	return nil
+}
+
+func (s *Session) shouldStop(stack []interpreter.Frame) (StopReason, bool) {
+	top := stack[len(stack)-1]
+	pos := top.Statement.Position()
+	loc := location{path: cleanPath(top.Path), line: pos.Line, depth: len(stack)}
+	newLine := loc != s.last || pos.Col <= s.lastCol
+	s.last, s.lastCol = loc, pos.Col
+	switch {
+	case s.pause != "":
+		return s.pause, true
+	case !newLine:
+		return "", false
+	case s.step == stepIn:
+		return Step, true
+	case s.step == stepOver && loc.depth <= s.stepDepth:
+		return Step, true
+	case s.step == stepOut && loc.depth < s.stepDepth:
+		return Step, true
+	case s.breakpoints[loc.path][loc.line]:
+		return Breakpoint, true
+	}
+	return "", false
+}
+
+func cleanPath(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Clean(path)
+}
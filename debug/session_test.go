@@ -0,0 +1,200 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// loopProgram prints n on every one of three runs of a while loop whose body is a single statement.
+const loopProgram = `const n = "0";
+while "keep going" {
+    print n;
+}
+print "done";
+`
+
+// loopAnswers lets the while loop in loopProgram run three times.
+var loopAnswers = []backend.Exchange{
+	{User: "keep going", Response: "EVALUATE_TRUE"},
+	{User: "keep going", Response: "EVALUATE_TRUE"},
+	{User: "keep going", Response: "EVALUATE_TRUE"},
+	{User: "keep going", Response: "EVALUATE_FALSE"},
+}
+
+const callProgram = `fn f x {
+    print x;
+    return x;
+}
+run a = f "hi";
+print a;
+`
+
+// debugRun runs src under a session, calling setup before it starts and act each time it stops.
+// It returns each stop as "reason line depth", and the error the program finished with.
+func debugRun(t *testing.T, src string, answers []backend.Exchange, setup func(s *Session, path string), act func(s *Session, stop int)) ([]string, error) {
+	t.Helper()
+	code, err := parser.ParseSource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.hl")
+	reasons := make(chan StopReason, 1)
+	session := NewSession(func(reason StopReason) { reasons <- reason })
+	setup(session, path)
+	interp, err := interpreter.New(strings.NewReader(""), io.Discard, interpreter.Options{
+		Model:    backend.NewReplayModel(answers),
+		Debugger: session,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- interp.Run(code, path, nil) }()
+	var stops []string
+	for {
+		select {
+		case reason := <-reasons:
+			stack := session.Stack()
+			top := stack[len(stack)-1]
+			stops = append(stops, fmt.Sprintf("%s %d %d", reason, top.Statement.Position().Line, len(stack)))
+			act(session, len(stops))
+		case err := <-done:
+			return stops, err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("program did not finish, stopped at %v", stops)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	cases := []struct {
+		name    string
+		src     string
+		answers []backend.Exchange
+		// breakpoints are lines to break on, and entry is set to stop before the first statement.
+		breakpoints []int
+		entry       bool
+		// steps are what to do at each stop, in turn, continuing once they run out.
+		steps []func(s *Session)
+		want  []string
+	}{
+		{
+			name:        "breakpoint in a one line loop body",
+			src:         loopProgram,
+			answers:     loopAnswers,
+			breakpoints: []int{3},
+			want:        []string{"breakpoint 3 1", "breakpoint 3 1", "breakpoint 3 1"},
+		},
+		{
+			name:    "step over a loop",
+			src:     loopProgram,
+			answers: loopAnswers,
+			entry:   true,
+			steps:   []func(s *Session){(*Session).StepOver, (*Session).StepOver, (*Session).StepOver, (*Session).StepOver, (*Session).StepOver},
+			want:    []string{"entry 1 1", "step 2 1", "step 3 1", "step 3 1", "step 3 1", "step 5 1"},
+		},
+		{
+			name:  "step in and out",
+			src:   callProgram,
+			entry: true,
+			steps: []func(s *Session){(*Session).StepIn, (*Session).StepIn, (*Session).StepOut},
+			want:  []string{"entry 1 1", "step 5 1", "step 2 2", "step 6 1"},
+		},
+		{
+			name:  "step over a call",
+			src:   callProgram,
+			entry: true,
+			steps: []func(s *Session){(*Session).StepIn, (*Session).StepOver},
+			want:  []string{"entry 1 1", "step 5 1", "step 6 1"},
+		},
+		{
+			name:        "breakpoint in a function",
+			src:         callProgram,
+			breakpoints: []int{3},
+			want:        []string{"breakpoint 3 2"},
+		},
+		{
+			name:        "several statements on a line",
+			src:         "print \"a\"; print \"b\";\nprint \"c\";\n",
+			breakpoints: []int{1, 2},
+			want:        []string{"breakpoint 1 1", "breakpoint 2 1"},
+		},
+		{
+			name:  "step through several statements on a line",
+			src:   "print \"a\"; print \"b\";\nprint \"c\";\n",
+			entry: true,
+			steps: []func(s *Session){(*Session).StepOver},
+			want:  []string{"entry 1 1", "step 2 1"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stops, err := debugRun(t, c.src, c.answers, func(s *Session, path string) {
+				s.SetBreakpoints(path, c.breakpoints)
+				if c.entry {
+					s.StopOnEntry()
+				}
+			}, func(s *Session, stop int) {
+				if stop <= len(c.steps) {
+					c.steps[stop-1](s)
+				} else {
+					s.Continue()
+				}
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(stops, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("expected stops\n%s\ngot\n%s", strings.Join(c.want, "\n"), strings.Join(stops, "\n"))
+			}
+		})
+	}
+}
+
+func TestSessionTerminate(t *testing.T) {
+	stops, err := debugRun(t, loopProgram, loopAnswers, func(s *Session, path string) {
+		s.SetBreakpoints(path, []int{3})
+	}, func(s *Session, stop int) {
+		s.Terminate()
+	})
+	if !errors.Is(err, ErrTerminated) {
+		t.Fatalf("expected ErrTerminated, got %v", err)
+	}
+	if len(stops) != 1 {
+		t.Errorf("expected one stop before terminating, got %v", stops)
+	}
+}
+
+func TestSessionChangeVariable(t *testing.T) {
+	var printed strings.Builder
+	code, err := parser.ParseSource("const a = \"before\";\nprint a;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.hl")
+	session := NewSession(nil)
+	session.onStop = func(StopReason) {
+		session.Stack()[0].Scope.Set("a", "after")
+		session.Continue()
+	}
+	session.SetBreakpoints(path, []int{2})
+	interp, err := interpreter.New(strings.NewReader(""), &printed, interpreter.Options{Debugger: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if printed.String() != "after\n" {
+		t.Errorf("expected the changed value to be printed, got %q", printed.String())
+	}
+}
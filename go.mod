@@ -1,5 +1,8 @@
-module hellm
+module github.com/JoshPattman/hellm
 
 go 1.23.1
 
-require github.com/JoshPattman/jpf v0.5.0
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/JoshPattman/jpf v0.5.0
+)
@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// ScriptArgs are the command line arguments given to a script, split into positional arguments and named flags.
+type ScriptArgs struct {
+	Positional []string
+	Flags      map[string]string
+	// Help is set if --help or -h was given.
+	Help bool
+}
+
+// ParseScriptArgs splits args into positional arguments and --name value or --name=value flags.
+// A flag with no value following it is set to "true". Everything after -- is positional.
+func ParseScriptArgs(args []string) ScriptArgs {
+	parsed := ScriptArgs{Flags: map[string]string{}}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			parsed.Positional = append(parsed.Positional, args[i+1:]...)
+			break
+		}
+		if arg == "--help" || arg == "-h" {
+			parsed.Help = true
+			continue
+		}
+		name, ok := strings.CutPrefix(arg, "--")
+		if !ok || name == "" {
+			parsed.Positional = append(parsed.Positional, arg)
+			continue
+		}
+		if name, val, ok := strings.Cut(name, "="); ok {
+			parsed.Flags[name] = val
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			parsed.Flags[name] = args[i+1]
+			i++
+		} else {
+			parsed.Flags[name] = "true"
+		}
+	}
+	return parsed
+}
+
+// ScriptUsage describes the arguments, flags and environment variables that a script reads with use statements.
+func ScriptUsage(scriptName string, code []parser.ASTNode) string {
+	uses := []parser.UseNode{}
+	parser.WalkNodes(code, func(node parser.ASTNode) {
+		if use, ok := node.(parser.UseNode); ok {
+			uses = append(uses, use)
+		}
+	})
+	slices.SortStableFunc(uses, func(a, b parser.UseNode) int {
+		if a.Source != b.Source {
+			return int(a.Source) - int(b.Source)
+		}
+		return a.ArgID - b.ArgID
+	})
+
+	synopsis := []string{"hellm run " + scriptName}
+	lines := map[parser.UseSource][]string{}
+	for _, use := range uses {
+		var name, desc string
+		switch use.Source {
+		case parser.UseArg:
+			name = fmt.Sprintf("<%s>", use.Ident)
+			desc = fmt.Sprintf("argument %d", use.ArgID)
+			if use.Default != nil {
+				synopsis = append(synopsis, "["+name+"]")
+			} else {
+				synopsis = append(synopsis, name)
+			}
+		case parser.UseFlag:
+			name = "--" + use.Name
+			desc = fmt.Sprintf("sets %s", use.Ident)
+			if use.Default != nil {
+				synopsis = append(synopsis, fmt.Sprintf("[%s <%s>]", name, use.Ident))
+			} else {
+				synopsis = append(synopsis, fmt.Sprintf("%s <%s>", name, use.Ident))
+			}
+		case parser.UseEnv:
+			name = use.Name
+			desc = fmt.Sprintf("sets %s", use.Ident)
+		}
+		if use.Default != nil {
+			desc += fmt.Sprintf(" (default %q)", *use.Default)
+		} else {
+			desc += " (required)"
+		}
+		lines[use.Source] = append(lines[use.Source], fmt.Sprintf("  %-20s %s", name, desc))
+	}
+
+	usage := "usage: " + strings.Join(synopsis, " ") + "\n"
+	sections := []struct {
+		source parser.UseSource
+		title  string
+	}{
+		{parser.UseArg, "arguments"},
+		{parser.UseFlag, "flags"},
+		{parser.UseEnv, "environment variables"},
+	}
+	for _, section := range sections {
+		if len(lines[section.source]) > 0 {
+			usage += "\n" + section.title + ":\n" + strings.Join(lines[section.source], "\n") + "\n"
+		}
+	}
+	return usage
+}
@@ -0,0 +1,129 @@
+package interpreter
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// NativeFunc is the Go implementation of a builtin function. It is called with one string per argument,
+// and must return one string per output.
+type NativeFunc func(args []string) ([]string, error)
+
+// Builtins are native functions that programs and modules can call with run, keyed by name.
+type Builtins map[string]Function
+
+// defaultBuiltins are the builtins every program can call.
+// Lists are passed around as text with one item per line, the same as the std library's prompts produce.
+var defaultBuiltins = Builtins{}
+
+// DefaultBuiltins returns a copy of the default builtins, which more can be registered in.
+func DefaultBuiltins() Builtins {
+	return maps.Clone(defaultBuiltins)
+}
+
+// Register adds a native function to b. Its arg names are only used to check how many args it is called with.
+func (b Builtins) Register(name string, args []string, native NativeFunc) {
+	b[name] = Function{
+		Def:    parser.FuncDefNode{Ident: name, Args: args},
+		Native: native,
+	}
+}
+
+func init() {
+	defaultBuiltins.Register("len", []string{"text"}, func(args []string) ([]string, error) {
+		return []string{strconv.Itoa(utf8.RuneCountInString(args[0]))}, nil
+	})
+	defaultBuiltins.Register("upper", []string{"text"}, func(args []string) ([]string, error) {
+		return []string{strings.ToUpper(args[0])}, nil
+	})
+	defaultBuiltins.Register("lower", []string{"text"}, func(args []string) ([]string, error) {
+		return []string{strings.ToLower(args[0])}, nil
+	})
+	defaultBuiltins.Register("trim", []string{"text"}, func(args []string) ([]string, error) {
+		return []string{strings.TrimSpace(args[0])}, nil
+	})
+	defaultBuiltins.Register("replace", []string{"text", "old", "new"}, func(args []string) ([]string, error) {
+		return []string{strings.ReplaceAll(args[0], args[1], args[2])}, nil
+	})
+	defaultBuiltins.Register("split", []string{"text", "sep"}, func(args []string) ([]string, error) {
+		return []string{strings.Join(strings.Split(args[0], args[1]), "\n")}, nil
+	})
+	defaultBuiltins.Register("join", []string{"lines", "sep"}, func(args []string) ([]string, error) {
+		return []string{strings.Join(strings.Split(args[0], "\n"), args[1])}, nil
+	})
+	defaultBuiltins.Register("regex_match", []string{"text", "pattern"}, func(args []string) ([]string, error) {
+		re, err := regexp.Compile(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression '%s': %w", args[1], err)
+		}
+		return []string{strconv.FormatBool(re.MatchString(args[0]))}, nil
+	})
+	defaultBuiltins.Register("json_get", []string{"json", "path"}, func(args []string) ([]string, error) {
+		value, err := jsonGet(args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+		return []string{value}, nil
+	})
+	defaultBuiltins.Register("now", nil, func(args []string) ([]string, error) {
+		return []string{time.Now().Format(time.RFC3339)}, nil
+	})
+	defaultBuiltins.Register("uuid", nil, func(args []string) ([]string, error) {
+		return []string{newUUID()}, nil
+	})
+}
+
+// jsonGet returns the value at a dot separated path (e.g. users.0.name) in the JSON document src.
+// Strings are returned as they are, and any other value is returned as JSON.
+func jsonGet(src, path string) (string, error) {
+	var value any
+	if err := json.Unmarshal([]byte(src), &value); err != nil {
+		return "", fmt.Errorf("json_get was not given valid JSON: %w", err)
+	}
+	if path != "" {
+		for _, key := range strings.Split(path, ".") {
+			switch v := value.(type) {
+			case map[string]any:
+				child, ok := v[key]
+				if !ok {
+					return "", fmt.Errorf("json_get could not find key '%s' of path '%s'", key, path)
+				}
+				value = child
+			case []any:
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(v) {
+					return "", fmt.Errorf("json_get could not find index '%s' of path '%s'", key, path)
+				}
+				value = v[i]
+			default:
+				return "", fmt.Errorf("json_get could not find '%s' of path '%s', as it is not an object or array", key, path)
+			}
+		}
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,57 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBuiltins(t *testing.T) {
+	doc := `{"users":[{"name":"Ann","age":30,"tags":["a","b"]}],"ok":true}`
+	cases := []struct {
+		fn   string
+		args []string
+		want string
+		err  string
+	}{
+		{fn: "json_get", args: []string{doc, "users.0.name"}, want: "Ann"},
+		{fn: "json_get", args: []string{doc, "users.0.age"}, want: "30"},
+		{fn: "json_get", args: []string{doc, "users.0.tags"}, want: `["a","b"]`},
+		{fn: "json_get", args: []string{doc, "ok"}, want: "true"},
+		{fn: "json_get", args: []string{`"just text"`, ""}, want: "just text"},
+		{fn: "json_get", args: []string{doc, "users.1.name"}, err: "could not find index '1'"},
+		{fn: "json_get", args: []string{doc, "missing"}, err: "could not find key 'missing'"},
+		{fn: "json_get", args: []string{doc, "ok.x"}, err: "as it is not an object or array"},
+		{fn: "json_get", args: []string{"{not json", "a"}, err: "json_get was not given valid JSON"},
+		{fn: "split", args: []string{"a,b,c", ","}, want: "a\nb\nc"},
+		{fn: "split", args: []string{"abc", ","}, want: "abc"},
+		{fn: "join", args: []string{"a\nb\nc", ", "}, want: "a, b, c"},
+		{fn: "regex_match", args: []string{"Hello.", `^[A-Z].*\.$`}, want: "true"},
+		{fn: "regex_match", args: []string{"hello", `^[A-Z]`}, want: "false"},
+		{fn: "regex_match", args: []string{"hello", "("}, err: "invalid regular expression '('"},
+		{fn: "len", args: []string{"größe"}, want: "5"},
+		{fn: "replace", args: []string{"a-b-c", "-", "+"}, want: "a+b+c"},
+		{fn: "trim", args: []string{"  hi \n"}, want: "hi"},
+	}
+	for _, c := range cases {
+		t.Run(c.fn+" "+strings.Join(c.args, " "), func(t *testing.T) {
+			interp, err := New(strings.NewReader(""), io.Discard, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs, err := interp.Call(c.fn, c.args...)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error containing %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outputs) != 1 || outputs[0] != c.want {
+				t.Errorf("expected %q, got %q", c.want, outputs)
+			}
+		})
+	}
+}
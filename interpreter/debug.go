@@ -0,0 +1,112 @@
+package interpreter
+
+import (
+	"maps"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// Debugger is asked before each statement runs whether to pause the program there.
+// It pauses the program by not returning until it should carry on, and stops it by returning an error.
+type Debugger interface {
+	// Break is called with the call stack, innermost frame last, just before the statement of the innermost frame runs.
+	// The frames' scopes may be read and changed until Break returns.
+	Break(stack []Frame) error
+}
+
+// Frame is the top level of a file, or a call to a hellm function, that is running.
+type Frame struct {
+	// Function is the name the function was called by, or empty for the top level of a file.
+	Function string
+	// Path is the file being run, or the std path of a standard library module. It may be empty for programs not loaded from a file.
+	Path string
+	// Statement is the statement the frame is running, and Scope is the scope it is running in.
+	Statement parser.ASTNode
+	Scope     *Scope
+}
+
+// callStack is the frames that are running, innermost last.
+type callStack struct {
+	frames []*Frame
+}
+
+func (c *callStack) push(f *Frame) {
+	c.frames = append(c.frames, f)
+}
+
+func (c *callStack) pop() {
+	c.frames = c.frames[:len(c.frames)-1]
+}
+
+// file is the file the innermost frame's code is in.
+func (c *callStack) file() string {
+	if len(c.frames) == 0 {
+		return ""
+	}
+	return c.frames[len(c.frames)-1].Path
+}
+
+// snapshot copies the frames so the debugger can hold on to them.
+func (c *callStack) snapshot() []Frame {
+	frames := make([]Frame, len(c.frames))
+	for i, f := range c.frames {
+		frames[i] = *f
+	}
+	return frames
+}
+
+// enter records that the statement n is about to run in scope, and tells the debugger, if there is one.
+func (e *env) enter(n parser.ASTNode, scope *Scope) error {
+	if len(e.calls.frames) == 0 {
+		return nil
+	}
+	top := e.calls.frames[len(e.calls.frames)-1]
+	top.Statement, top.Scope = n, scope
+	if e.debugger == nil {
+		return nil
+	}
+	if err := e.debugger.Break(e.calls.snapshot()); err != nil {
+		return &debuggerStop{err}
+	}
+	return nil
+}
+
+// debuggerStop is an error from the debugger stopping the program, which try statements must not catch.
+type debuggerStop struct {
+	err error
+}
+
+func (s *debuggerStop) Error() string {
+	return s.err.Error()
+}
+
+func (s *debuggerStop) Unwrap() error {
+	return s.err
+}
+
+// ScopeLevel is the variables defined at one level of a scope.
+type ScopeLevel struct {
+	Variables map[string]string
+	Hidden    map[string]bool
+}
+
+// Levels returns a copy of each level of variables in the scope, outermost first.
+func (s *Scope) Levels() []ScopeLevel {
+	levels := make([]ScopeLevel, len(s.variableLevels))
+	for i := range s.variableLevels {
+		levels[i] = ScopeLevel{
+			Variables: maps.Clone(s.variableLevels[i]),
+			Hidden:    maps.Clone(s.hiddenLevels[i]),
+		}
+	}
+	return levels
+}
+
+// Funcs returns every function defined in the scope, not including builtins.
+func (s *Scope) Funcs() map[string]Function {
+	funcs := map[string]Function{}
+	for _, level := range s.funcitonLevels {
+		maps.Copy(funcs, level)
+	}
+	return funcs
+}
@@ -0,0 +1,84 @@
+package interpreter
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// stopAt is a debugger that keeps the stack it was given at each statement, and stops the program at line.
+type stopAt struct {
+	line   int
+	stacks [][]Frame
+}
+
+var errStopped = errors.New("stopped")
+
+func (d *stopAt) Break(stack []Frame) error {
+	d.stacks = append(d.stacks, stack)
+	if stack[len(stack)-1].Statement.Position().Line == d.line {
+		return errStopped
+	}
+	return nil
+}
+
+func TestDebuggerStop(t *testing.T) {
+	code, err := parser.ParseSource(`fn f x {
+    const y = "{x}!";
+    return y;
+}
+try {
+    run z = f "hi";
+} catch e {
+    print "caught";
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugger := &stopAt{line: 3}
+	out := &strings.Builder{}
+	interp, err := New(strings.NewReader(""), out, Options{Debugger: debugger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, "main.hl", nil); !errors.Is(err, errStopped) {
+		t.Fatalf("expected the debugger to stop the program, got %v", err)
+	}
+	if out.Len() > 0 {
+		t.Errorf("expected the stop not to be caught, but the catch block printed %q", out.String())
+	}
+	stack := debugger.stacks[len(debugger.stacks)-1]
+	if len(stack) != 2 || stack[0].Function != "" || stack[1].Function != "f" || filepath.Base(stack[1].Path) != "main.hl" {
+		t.Fatalf("expected to stop in f called from the top level, got %+v", stack)
+	}
+	levels := stack[1].Scope.Levels()
+	if y := levels[len(levels)-1].Variables["y"]; y != "hi!" {
+		t.Errorf("expected y to be hi! in f's scope, got %q", y)
+	}
+	if _, ok := stack[0].Scope.Funcs()["f"]; !ok {
+		t.Errorf("expected f in the functions of the top level scope")
+	}
+}
+
+func TestDebuggerBreaksEachStatement(t *testing.T) {
+	debugger := &stopAt{}
+	interp, err := New(strings.NewReader(""), io.Discard, Options{Debugger: debugger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := parser.ParseSource("const a = \"x\";\nconst b = \"y\";\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(debugger.stacks) != 2 {
+		t.Errorf("expected a break before each of the two statements, got %d", len(debugger.stacks))
+	}
+}
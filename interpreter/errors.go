@@ -0,0 +1,213 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/lexer"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// UndefinedVariableError is returned when a variable that is not in scope is used.
+type UndefinedVariableError struct {
+	Name string
+}
+
+func (e *UndefinedVariableError) Error() string {
+	return fmt.Sprintf("variable %s is not in scope", e.Name)
+}
+
+// UndefinedFunctionError is returned when a function that is not defined is called.
+type UndefinedFunctionError struct {
+	Name string
+}
+
+func (e *UndefinedFunctionError) Error() string {
+	return fmt.Sprintf("function %s is not defined", e.Name)
+}
+
+// ArityError is returned when a function is called with the wrong number of arguments.
+type ArityError struct {
+	Function string
+	Expected int
+	Got      int
+}
+
+func (e *ArityError) Error() string {
+	return fmt.Sprintf("function %s expected %d args but got %d", e.Function, e.Expected, e.Got)
+}
+
+// ModelError is returned when the model fails to answer a let, if, while or assert statement, or answers it with something unusable.
+// If the model refused to answer because it reached a limit, Err is a *backend.BudgetError.
+type ModelError struct {
+	// Statement is the kind of statement that asked the model: let, if, while or assert.
+	Statement string
+	Err       error
+}
+
+func (e *ModelError) Error() string {
+	return fmt.Sprintf("error interpreting %s node: %v", e.Statement, e.Err)
+}
+
+func (e *ModelError) Unwrap() error {
+	return e.Err
+}
+
+// ThrownError is returned by a throw statement.
+type ThrownError struct {
+	Message string
+	// Hidden is set if the message is, or was built from, a hidden variable.
+	Hidden bool
+}
+
+func (e *ThrownError) Error() string {
+	return e.Message
+}
+
+// RedactedError is the message of err, with the message of any hidden error thrown in it replaced by <hidden>, for writing to traces and snapshots.
+func RedactedError(err error) string {
+	msg := err.Error()
+	var thrown *ThrownError
+	if errors.As(err, &thrown) && thrown.Hidden && thrown.Message != "" {
+		msg = strings.ReplaceAll(msg, thrown.Message, hiddenValue)
+	}
+	return msg
+}
+
+// ErrorKind names the kind of err, as given to catch blocks: thrown, assertion, budget, model, undefined_variable, undefined_function, arity, parse or error.
+func ErrorKind(err error) string {
+	var (
+		thrown    *ThrownError
+		assertion *AssertionError
+		budget    *backend.BudgetError
+		model     *ModelError
+		undefVar  *UndefinedVariableError
+		undefFunc *UndefinedFunctionError
+		arity     *ArityError
+		parseErr  *parser.ParseError
+	)
+	switch {
+	case errors.As(err, &thrown):
+		return "thrown"
+	case errors.As(err, &assertion):
+		return "assertion"
+	case errors.As(err, &budget):
+		return "budget"
+	case errors.As(err, &model):
+		return "model"
+	case errors.As(err, &undefVar):
+		return "undefined_variable"
+	case errors.As(err, &undefFunc):
+		return "undefined_function"
+	case errors.As(err, &arity):
+		return "arity"
+	case errors.As(err, &parseErr):
+		return "parse"
+	}
+	return "error"
+}
+
+// AssertionError is returned when an assert, assert_eq or assert_matches statement fails.
+type AssertionError struct {
+	// Statement is the assertion that failed.
+	Statement string
+	// Reason is why it failed, with any hidden values replaced.
+	Reason string
+}
+
+func (e *AssertionError) Error() string {
+	return fmt.Sprintf("assertion failed: %s (%s)", e.Statement, e.Reason)
+}
+
+// StackFrame is a function call, or the top level of a file, on the hellm call stack when an error happened.
+type StackFrame struct {
+	// Function is the name the function was called by, or empty for the top level of a file.
+	Function string
+	// Path is the file being run, if there is one.
+	Path string
+	// Pos is where the statement the frame was running starts. For every frame but the innermost, that is the call site of the frame inside it.
+	Pos lexer.Pos
+	// Statement is the first line of the formatted statement.
+	Statement string
+}
+
+func (f StackFrame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<top level>"
+	}
+	if f.Path == "" {
+		return fmt.Sprintf("%s (%s) %s", name, f.Pos, f.Statement)
+	}
+	return fmt.Sprintf("%s (%s:%s) %s", name, f.Path, f.Pos, f.Statement)
+}
+
+// RuntimeError is an error that stopped a program, with the call stack at the point it happened.
+// It wraps one of the typed errors in this package where the kind of error is known, so use errors.As to find out what went wrong.
+type RuntimeError struct {
+	Err error
+	// Stack is the call stack when the error happened, innermost frame first.
+	Stack []StackFrame
+	// Scope is the variables in scope where the error happened, with hidden values replaced.
+	// It is only set if Options.ErrorScope is.
+	Scope map[string]string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// StackTrace describes the call stack of the error, a line per frame, followed by its scope if it was recorded.
+func (e *RuntimeError) StackTrace() string {
+	b := &strings.Builder{}
+	for _, f := range e.Stack {
+		fmt.Fprintf(b, "  at %s\n", f)
+	}
+	if e.Scope != nil {
+		b.WriteString("scope:\n")
+		for _, name := range slices.Sorted(maps.Keys(e.Scope)) {
+			fmt.Fprintf(b, "  %s = %q\n", name, e.Scope[name])
+		}
+	}
+	return b.String()
+}
+
+// runtimeError wraps err, which happened running the statement n in scope, with the call stack.
+// Errors that already have a call stack are returned as they are, so the stack is where the error first happened.
+func (e *env) runtimeError(err error, n parser.ASTNode, scope *Scope) error {
+	var existing *RuntimeError
+	if errors.As(err, &existing) {
+		return err
+	}
+	rerr := &RuntimeError{Err: err}
+	for i := len(e.calls.frames) - 1; i >= 0; i-- {
+		f := e.calls.frames[i]
+		statement := f.Statement
+		if i == len(e.calls.frames)-1 {
+			statement = n
+		}
+		frame := StackFrame{Function: f.Function, Path: f.Path}
+		if statement != nil {
+			frame.Pos, frame.Statement = statement.Position(), statementSummary(statement)
+		}
+		rerr.Stack = append(rerr.Stack, frame)
+	}
+	if e.errorScope {
+		rerr.Scope = map[string]string{}
+		for k, v := range scope.KVPs() {
+			if scope.IsHidden(k) {
+				v = hiddenValue
+			}
+			rerr.Scope[k] = v
+		}
+	}
+	return rerr
+}
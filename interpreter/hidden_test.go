@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// TestHiddenTrace checks that hidden values never reach a trace, and that the events they would have been in say <hidden> instead.
+func TestHiddenTrace(t *testing.T) {
+	code, err := parser.ParseSource(`
+const hidden secret = "s3cr3t";
+let hidden token = "Make up a token";
+fn boom x {
+    throw "inner {x}";
+}
+try {
+    run boom secret;
+} catch e {
+    print "caught";
+}
+run boom secret;
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := &eventRecorder{}
+	model := backend.NewReplayModel([]backend.Exchange{{User: "Make up a token", Response: "TOPSECRET"}})
+	interp, err := New(strings.NewReader(""), io.Discard, Options{Model: model, Tracer: tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, "", nil); err == nil {
+		t.Fatal("expected the uncaught throw to fail the run")
+	}
+	if token, _ := interp.Scope().Get("token"); token != "TOPSECRET" {
+		t.Fatalf("expected the hidden token to be set, got %q", token)
+	}
+	redacted := map[EventKind]bool{}
+	for _, ev := range tracer.events {
+		// The secret is written out in the source, which statements are traced with
+		ev.Statement = ""
+		data, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "TOPSECRET") {
+			t.Errorf("%s event leaks the hidden value: %s", ev.Kind, data)
+		}
+		if ev.Error == hiddenValue || ev.Response == hiddenValue {
+			redacted[ev.Kind] = true
+		}
+	}
+	for _, kind := range []EventKind{StatementExit, FunctionReturn, ErrorCaught, RunError, LLMResponse} {
+		if !redacted[kind] {
+			t.Errorf("expected a %s event with %s in place of a hidden value", kind, hiddenValue)
+		}
+	}
+}
+
+func TestRedactedError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "hidden", err: &ThrownError{Message: "inner s3cr3t", Hidden: true}, want: "<hidden>"},
+		{name: "wrapped", err: &ModelError{Statement: "let", Err: &ThrownError{Message: "s3cr3t", Hidden: true}}, want: "error interpreting let node: <hidden>"},
+		{name: "not hidden", err: &ThrownError{Message: "plain"}, want: "plain"},
+		{name: "other", err: &UndefinedVariableError{Name: "x"}, want: (&UndefinedVariableError{Name: "x"}).Error()},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := RedactedError(c.err); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
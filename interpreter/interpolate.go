@@ -0,0 +1,34 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// interpolate replaces each variable reference in s with the variable's value from scope.
+// If forPrompt is set, referencing a hidden variable is an error, as the result will be sent to the model.
+// The returned bool reports whether any of the referenced variables were hidden.
+func interpolate(s string, scope *Scope, forPrompt bool) (string, bool, error) {
+	out := strings.Builder{}
+	usedHidden := false
+	for _, part := range parser.ParseInterpolation(s) {
+		if part.Ident == "" {
+			out.WriteString(part.Text)
+			continue
+		}
+		val, err := scope.Get(part.Ident)
+		if err != nil {
+			return "", false, err
+		}
+		if scope.IsHidden(part.Ident) {
+			if forPrompt {
+				return "", false, fmt.Errorf("hidden variable %s cannot be used in a prompt", part.Ident)
+			}
+			usedHidden = true
+		}
+		out.WriteString(val)
+	}
+	return out.String(), usedHidden, nil
+}
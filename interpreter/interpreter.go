@@ -0,0 +1,1020 @@
+package interpreter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+type Scope struct {
+	variableLevels []map[string]string
+	hiddenLevels   []map[string]bool
+	funcitonLevels []map[string]Function
+	// builtins are the native functions that can be called when no function of the same name is defined.
+	builtins Builtins
+}
+
+// Function is a function that can be called with run.
+type Function struct {
+	Def parser.FuncDefNode
+	// Module is the scope of the module the function was imported from, and decides which functions it can call.
+	// It is nil for functions defined in the running program, which can call any function their caller can.
+	Module *Scope
+	// Native is set for builtin functions implemented in Go, in which case Def has no code.
+	Native NativeFunc
+	// Path is the file the function was defined in, if it was defined in one.
+	Path string
+}
+
+// NewScope creates an empty scope that can call the default builtins.
+func NewScope() *Scope {
+	return newScope(defaultBuiltins)
+}
+
+func newScope(builtins Builtins) *Scope {
+	return &Scope{
+		builtins: builtins,
+		variableLevels: []map[string]string{
+			{},
+		},
+		hiddenLevels: []map[string]bool{
+			{},
+		},
+		funcitonLevels: []map[string]Function{
+			{},
+		},
+	}
+}
+
+func (s *Scope) Set(key, val string) {
+	s.set(key, val, false)
+}
+
+// SetHidden sets a variable that can be used as normal, but is never shown to the model.
+func (s *Scope) SetHidden(key, val string) {
+	s.set(key, val, true)
+}
+
+func (s *Scope) set(key, val string, hidden bool) {
+	i := len(s.variableLevels) - 1
+	for li, level := range s.variableLevels {
+		if _, ok := level[key]; ok {
+			i = li
+			break
+		}
+	}
+	s.variableLevels[i][key] = val
+	if hidden {
+		s.hiddenLevels[i][key] = true
+	} else {
+		delete(s.hiddenLevels[i], key)
+	}
+}
+
+func (s *Scope) IsHidden(key string) bool {
+	for _, level := range s.hiddenLevels {
+		if level[key] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) Get(key string) (string, error) {
+	for _, level := range s.variableLevels {
+		if val, ok := level[key]; ok {
+			return val, nil
+		}
+	}
+	return "", &UndefinedVariableError{Name: key}
+}
+
+func (s *Scope) Has(key string) bool {
+	for _, level := range s.variableLevels {
+		if _, ok := level[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) Del(key string) error {
+	for i, level := range s.variableLevels {
+		if _, ok := level[key]; ok {
+			delete(level, key)
+			delete(s.hiddenLevels[i], key)
+			return nil
+		}
+	}
+	return &UndefinedVariableError{Name: key}
+}
+
+// Function support
+
+func (s *Scope) SetFunc(key string, fn Function) {
+	for _, level := range s.funcitonLevels {
+		if _, ok := level[key]; ok {
+			level[key] = fn
+			return
+		}
+	}
+	s.funcitonLevels[len(s.funcitonLevels)-1][key] = fn
+}
+
+// GetFunc returns the function defined as key, or the builtin named key if there is no such function.
+func (s *Scope) GetFunc(key string) (Function, error) {
+	for _, level := range s.funcitonLevels {
+		if fn, ok := level[key]; ok {
+			return fn, nil
+		}
+	}
+	if fn, ok := s.builtins[key]; ok {
+		return fn, nil
+	}
+	return Function{}, &UndefinedFunctionError{Name: key}
+}
+
+func (s *Scope) HasFunc(key string) bool {
+	for _, level := range s.funcitonLevels {
+		if _, ok := level[key]; ok {
+			return true
+		}
+	}
+	_, ok := s.builtins[key]
+	return ok
+}
+
+func (s *Scope) DelFunc(key string) error {
+	for _, level := range s.funcitonLevels {
+		if _, ok := level[key]; ok {
+			delete(level, key)
+			return nil
+		}
+	}
+	return &UndefinedFunctionError{Name: key}
+}
+
+func (s *Scope) SubScope() *Scope {
+	newVarLevels := make([]map[string]string, len(s.variableLevels)+1)
+	copy(newVarLevels, s.variableLevels)
+	newVarLevels[len(newVarLevels)-1] = make(map[string]string)
+
+	newHiddenLevels := make([]map[string]bool, len(s.hiddenLevels)+1)
+	copy(newHiddenLevels, s.hiddenLevels)
+	newHiddenLevels[len(newHiddenLevels)-1] = make(map[string]bool)
+
+	newFuncLevels := make([]map[string]Function, len(s.funcitonLevels)+1)
+	copy(newFuncLevels, s.funcitonLevels)
+	newFuncLevels[len(newFuncLevels)-1] = make(map[string]Function)
+
+	return &Scope{
+		variableLevels: newVarLevels,
+		hiddenLevels:   newHiddenLevels,
+		funcitonLevels: newFuncLevels,
+		builtins:       s.builtins,
+	}
+}
+
+func (s *Scope) KVPs() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, l := range s.variableLevels {
+			for k, v := range l {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Copies the functions to the innermost level in s
+func (s *Scope) CopyFuncsFrom(other *Scope) {
+	for _, level := range other.funcitonLevels {
+		for k, v := range level {
+			s.funcitonLevels[len(s.funcitonLevels)-1][k] = v
+		}
+	}
+}
+
+// OutputMode controls how print statements write their output.
+type OutputMode string
+
+const (
+	// TextOutput prints each value as a plain line of text.
+	TextOutput OutputMode = "text"
+	// JSONOutput prints each value as a JSON object on its own line, tagged with the stream and source line it came from.
+	JSONOutput OutputMode = "json"
+)
+
+// Options configures how a program is interpreted.
+type Options struct {
+	// Model answers every LLM-backed statement.
+	Model jpf.Model
+	// Price is what the model costs, used to report the cost of each call in traces.
+	Price backend.Price
+	// Prompts builds the system prompts sent to the model. The built-in prompts are used if nil.
+	Prompts *Prompts
+	// Narrow only sends the model variables that a statement lists in its using clause.
+	Narrow bool
+	// Stderr is where eprint statements write to. Defaults to os.Stderr.
+	Stderr io.Writer
+	// Output is how print statements format their output. Defaults to TextOutput.
+	Output OutputMode
+	// FSRoot is the directory that read, write and append statements are restricted to.
+	// If it is empty, scripts cannot access files at all.
+	FSRoot string
+	// DryRun stops write and append statements from changing any files.
+	DryRun bool
+	// Builtins are the native functions that programs can call with run. The default builtins are used if nil.
+	Builtins Builtins
+	// Tracer is told about each statement, model call, function call and file access as it happens, if it is set.
+	Tracer Tracer
+	// Debugger is asked before each statement whether to pause the program, if it is set.
+	Debugger Debugger
+	// ErrorScope records the variables in scope where an error happened in the RuntimeError it is returned as.
+	ErrorScope bool
+}
+
+// env is the state shared by every statement of a single run.
+type env struct {
+	model   jpf.Model
+	price   backend.Price
+	prompts *Prompts
+	narrow  bool
+	args    ScriptArgs
+	stdin   *bufio.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	output  OutputMode
+	fs      *sandbox
+	dryRun  bool
+	// file is the file being interpreted, and dir is its directory, which imports are relative to.
+	file     string
+	dir      string
+	modules  *moduleCache
+	builtins Builtins
+	tracer   Tracer
+	debugger Debugger
+	calls    *callStack
+	// errorScope is whether runtime errors record the scope they happened in.
+	errorScope bool
+}
+
+// Interpreter runs programs in a global scope that is kept between runs,
+// so that the functions and variables one program defines can be used later.
+type Interpreter struct {
+	env   *env
+	scope *Scope
+}
+
+// New creates an interpreter that reads input from stdin and prints to stdout.
+func New(stdin io.Reader, stdout io.Writer, opts Options) (*Interpreter, error) {
+	fs, err := newSandbox(opts.FSRoot)
+	if err != nil {
+		return nil, err
+	}
+	e := &env{
+		model:      opts.Model,
+		price:      opts.Price,
+		prompts:    opts.Prompts,
+		narrow:     opts.Narrow,
+		stdin:      bufio.NewReader(stdin),
+		stdout:     stdout,
+		stderr:     opts.Stderr,
+		output:     opts.Output,
+		fs:         fs,
+		dryRun:     opts.DryRun,
+		modules:    newModuleCache(),
+		builtins:   opts.Builtins,
+		tracer:     opts.Tracer,
+		debugger:   opts.Debugger,
+		calls:      &callStack{},
+		errorScope: opts.ErrorScope,
+	}
+	if e.prompts == nil {
+		e.prompts = DefaultPrompts()
+	}
+	if e.stderr == nil {
+		e.stderr = os.Stderr
+	}
+	if e.output == "" {
+		e.output = TextOutput
+	}
+	if e.builtins == nil {
+		e.builtins = DefaultBuiltins()
+	}
+	return &Interpreter{env: e, scope: newScope(e.builtins)}, nil
+}
+
+// Run interprets code in the global scope, with args as the program's command line arguments.
+// Imports are resolved relative to path, the file the code was loaded from, or the working directory if it is empty.
+func (in *Interpreter) Run(code []parser.ASTNode, path string, args []string) error {
+	return in.run(path, args, func() error {
+		_, err := interpret(code, in.env, in.scope)
+		return err
+	})
+}
+
+// Test interprets code in the global scope, then runs the body of its test block called name in a scope of its own.
+func (in *Interpreter) Test(code []parser.ASTNode, path, name string) error {
+	var test *parser.TestNode
+	for _, node := range code {
+		if n, ok := node.(parser.TestNode); ok && n.Name == name {
+			test = &n
+			break
+		}
+	}
+	if test == nil {
+		return fmt.Errorf("there is no test called '%s'", name)
+	}
+	return in.run(path, nil, func() error {
+		if _, err := interpret(code, in.env, in.scope); err != nil {
+			return err
+		}
+		_, err := interpret(test.Statements, in.env, in.scope.SubScope())
+		return err
+	})
+}
+
+// run sets up the env to run the file at path with args, then calls interpretFile.
+func (in *Interpreter) run(path string, args []string, interpretFile func() error) error {
+	in.env.args = ParseScriptArgs(args)
+	in.env.file, in.env.dir = path, filepath.Dir(path)
+	if path != "" {
+		// The running file counts as being loaded, so that a module importing it is a cycle
+		if full, err := filepath.Abs(path); err == nil {
+			in.env.file = full
+			in.env.modules.loading = append(in.env.modules.loading, full)
+			defer func() { in.env.modules.loading = in.env.modules.loading[:len(in.env.modules.loading)-1] }()
+		}
+	}
+	in.env.calls.push(&Frame{Path: in.env.file})
+	defer in.env.calls.pop()
+	err := interpretFile()
+	if err != nil {
+		in.env.trace(Event{Kind: RunError, Error: RedactedError(err)})
+	}
+	return err
+}
+
+// Scope returns the global scope, with the variables and functions that the programs run so far have left in it.
+func (in *Interpreter) Scope() *Scope {
+	return in.scope
+}
+
+// Call runs the function called name from the global scope with args, returning the values it returns.
+func (in *Interpreter) Call(name string, args ...string) ([]string, error) {
+	fn, err := in.scope.GetFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(fn.Def.Args) != len(args) {
+		return nil, &ArityError{Function: name, Expected: len(fn.Def.Args), Got: len(args)}
+	}
+	returnVal, _, err := call(name, fn, args, make([]bool, len(args)), in.env, in.scope)
+	if err != nil {
+		in.env.trace(Event{Kind: RunError, Function: name, Error: RedactedError(err)})
+	}
+	return returnVal, err
+}
+
+// If an interpret returns a non-nil value list, a return has been triggered and needs to be caught by a function. It will propagate.
+func interpret(code []parser.ASTNode, e *env, scope *Scope) ([]string, error) {
+	for _, node := range code {
+		if vals, err := interpretNode(node, e, scope); err != nil {
+			return nil, err
+		} else if vals != nil {
+			return vals, nil
+		}
+	}
+	return nil, nil
+}
+
+func interpretNode(code parser.ASTNode, e *env, scope *Scope) ([]string, error) {
+	if _, ok := code.(parser.SourceCommentNode); ok {
+		return nil, nil
+	}
+	if err := e.enter(code, scope); err != nil {
+		return nil, err
+	}
+	if e.tracer == nil {
+		vals, err := interpretStatement(code, e, scope)
+		if err != nil {
+			return nil, e.runtimeError(err, code, scope)
+		}
+		return vals, nil
+	}
+	e.trace(Event{Kind: StatementEnter}.at(code))
+	start := time.Now()
+	vals, err := interpretStatement(code, e, scope)
+	if err != nil {
+		err = e.runtimeError(err, code, scope)
+	}
+	exit := Event{Kind: StatementExit, DurationMS: durationMS(start)}.at(code)
+	if err != nil {
+		exit.Error = RedactedError(err)
+	}
+	e.trace(exit)
+	return vals, err
+}
+
+func interpretStatement(code parser.ASTNode, e *env, scope *Scope) ([]string, error) {
+	switch code := code.(type) {
+	case parser.LetNode:
+		err := interpretLet(code, e, scope)
+		return nil, err
+	case parser.ConstNode:
+		err := interpretConst(code, scope)
+		return nil, err
+	case parser.UseNode:
+		err := interpretUse(code, scope, e.args)
+		return nil, err
+	case parser.IfNode:
+		return interpretIf(code, e, scope)
+	case parser.WhileNode:
+		return interpretWhile(code, e, scope)
+	case parser.TryNode:
+		return interpretTry(code, e, scope)
+	case parser.ThrowNode:
+		return nil, interpretThrow(code, scope)
+	case parser.TestNode:
+		// Tests are only run by Interpreter.Test
+		return nil, nil
+	case parser.AssertNode:
+		return nil, interpretAssert(code, e, scope)
+	case parser.AssertEqNode:
+		return nil, interpretAssertEq(code, scope)
+	case parser.AssertMatchesNode:
+		return nil, interpretAssertMatches(code, scope)
+	case parser.PrintNode:
+		err := interpretPrint(code, e, scope)
+		return nil, err
+	case parser.InputNode:
+		err := interpretInput(code, e, scope)
+		return nil, err
+	case parser.ReadNode:
+		err := interpretRead(code, e, scope)
+		return nil, err
+	case parser.WriteNode:
+		err := interpretWrite(code, e, scope)
+		return nil, err
+	case parser.ImportNode:
+		err := interpretImport(code, e, scope)
+		return nil, err
+	case parser.CommentNode:
+		err := interpretComment(code, scope)
+		return nil, err
+	case parser.DelNode:
+		err := interpretDel(code, scope)
+		return nil, err
+	case parser.FuncDefNode:
+		err := interpretFuncDef(code, e, scope)
+		return nil, err
+	case parser.RunNode:
+		return interpretRun(code, e, scope)
+	case parser.ReturnNode:
+		return interpretReturn(code, scope)
+	default:
+		panic(fmt.Sprintf("unrecognised node type %T", code))
+	}
+}
+
+func interpretLet(n parser.LetNode, e *env, scope *Scope) error {
+	resp, err := ask(e, n, "let", n.Value, n.Using, scope)
+	if err != nil {
+		return err
+	}
+	if n.Hidden {
+		scope.SetHidden(n.Ident, strings.TrimSpace(resp))
+		return nil
+	}
+	scope.Set(n.Ident, strings.TrimSpace(resp))
+	return nil
+}
+
+// ask sends the text of statement n to the model with the named system prompt, returning the model's reply.
+func ask(e *env, n parser.ASTNode, name, text string, using []string, scope *Scope) (string, error) {
+	vars, err := promptVariables(e, text, using, scope)
+	if err != nil {
+		return "", err
+	}
+	prompt, err := e.prompts.Render(name, vars)
+	if err != nil {
+		return "", err
+	}
+	value, _, err := interpolate(text, scope, true)
+	if err != nil {
+		return "", err
+	}
+	e.trace(Event{Kind: LLMRequest, Prompt: prompt, Text: value, Variables: vars}.at(n))
+	start := time.Now()
+	_, resp, usage, err := e.model.Respond([]jpf.Message{
+		{Role: jpf.SystemRole, Content: prompt},
+		{Role: jpf.UserRole, Content: value},
+	})
+	ev := Event{
+		Kind:         LLMResponse,
+		Response:     resp.Content,
+		DurationMS:   durationMS(start),
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		Cost:         e.price.Cost(usage.InputTokens, usage.OutputTokens),
+	}.at(n)
+	if let, ok := n.(parser.LetNode); ok && let.Hidden {
+		ev.Response = hiddenValue
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	e.trace(ev)
+	if err != nil {
+		return "", &ModelError{Statement: name, Err: err}
+	}
+	return resp.Content, nil
+}
+
+// promptVariables picks the variables to include in the prompt for a statement with the given text and using clause.
+// Hidden variables are never included, and neither are variables interpolated into the text unless the using clause
+// lists them, as their values are already in the question. If the statement has a using clause, or narrowing is on,
+// only the variables it lists are included. Otherwise every variable in scope is. They are sorted by name.
+func promptVariables(e *env, text string, using []string, scope *Scope) ([]PromptVariable, error) {
+	narrow := e.narrow || using != nil
+	wanted := map[string]bool{}
+	for _, ident := range using {
+		if !scope.Has(ident) {
+			return nil, &UndefinedVariableError{Name: ident}
+		}
+		wanted[ident] = true
+	}
+	interpolated := map[string]bool{}
+	for _, ident := range parser.ReferencedVariables(text) {
+		interpolated[ident] = true
+	}
+	// Variables are listed in order of name, so that the same scope always gives the same prompt
+	kvps := maps.Collect(scope.KVPs())
+	vars := []PromptVariable{}
+	for _, k := range slices.Sorted(maps.Keys(kvps)) {
+		if scope.IsHidden(k) || (narrow || interpolated[k]) && !wanted[k] {
+			continue
+		}
+		vars = append(vars, PromptVariable{Name: k, Value: kvps[k]})
+	}
+	return vars, nil
+}
+
+func interpretConst(n parser.ConstNode, scope *Scope) error {
+	value, usedHidden, err := interpolate(n.Value, scope, false)
+	if err != nil {
+		return err
+	}
+	// A const built from a hidden variable would leak it, so it is hidden too
+	if n.Hidden || usedHidden {
+		scope.SetHidden(n.Ident, value)
+		return nil
+	}
+	scope.Set(n.Ident, value)
+	return nil
+}
+
+func interpretUse(n parser.UseNode, scope *Scope, args ScriptArgs) error {
+	var val string
+	var ok bool
+	var desc string
+	switch n.Source {
+	case parser.UseArg:
+		ok = n.ArgID >= 0 && n.ArgID < len(args.Positional)
+		if ok {
+			val = args.Positional[n.ArgID]
+		}
+		desc = fmt.Sprintf("argument id %d is out or range for arguments", n.ArgID)
+	case parser.UseFlag:
+		val, ok = args.Flags[n.Name]
+		desc = fmt.Sprintf("flag --%s was not given", n.Name)
+	case parser.UseEnv:
+		val, ok = os.LookupEnv(n.Name)
+		desc = fmt.Sprintf("environment variable %s is not set", n.Name)
+	}
+	if !ok {
+		if n.Default == nil {
+			return fmt.Errorf("%s (see --help)", desc)
+		}
+		val = *n.Default
+	}
+	scope.Set(n.Ident, val)
+	return nil
+}
+
+func interpretIf(n parser.IfNode, e *env, scope *Scope) ([]string, error) {
+	resp, err := ask(e, n, "if", n.Condition, n.Using, scope)
+	if err != nil {
+		return nil, err
+	}
+	var taken bool
+	if strings.Contains(resp, "EVALUATE_TRUE") {
+		taken = true
+	} else if !strings.Contains(resp, "EVALUATE_FALSE") {
+		return nil, &ModelError{Statement: "if", Err: errors.New("llm did not decide")}
+	}
+	e.trace(Event{Kind: Branch, Taken: &taken}.at(n))
+	subScope := scope.SubScope()
+	if taken {
+		return interpret(n.IfStatements, e, subScope)
+	}
+	return interpret(n.ElseStatements, e, subScope)
+}
+
+func interpretAssert(n parser.AssertNode, e *env, scope *Scope) error {
+	resp, err := ask(e, n, "assert", n.Condition, n.Using, scope)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(resp, "EVALUATE_TRUE") {
+		return nil
+	} else if !strings.Contains(resp, "EVALUATE_FALSE") {
+		return &ModelError{Statement: "assert", Err: errors.New("llm did not decide")}
+	}
+	return &AssertionError{Statement: statementSummary(n), Reason: "the model judged it false"}
+}
+
+func interpretAssertEq(n parser.AssertEqNode, scope *Scope) error {
+	left, leftHidden, err := resolveOperand(n.Left, scope)
+	if err != nil {
+		return err
+	}
+	right, rightHidden, err := resolveOperand(n.Right, scope)
+	if err != nil {
+		return err
+	}
+	if left == right {
+		return nil
+	}
+	return &AssertionError{
+		Statement: statementSummary(n),
+		Reason:    fmt.Sprintf("%s != %s", quoteUnlessHidden(left, leftHidden), quoteUnlessHidden(right, rightHidden)),
+	}
+}
+
+func interpretAssertMatches(n parser.AssertMatchesNode, scope *Scope) error {
+	pattern, err := regexp.Compile(n.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid assert_matches pattern: %w", err)
+	}
+	value, hidden, err := resolveOperand(n.Value, scope)
+	if err != nil {
+		return err
+	}
+	if pattern.MatchString(value) {
+		return nil
+	}
+	return &AssertionError{
+		Statement: statementSummary(n),
+		Reason:    fmt.Sprintf("%s does not match %q", quoteUnlessHidden(value, hidden), n.Pattern),
+	}
+}
+
+// quoteUnlessHidden quotes a value for an error message, or replaces it if it is hidden.
+func quoteUnlessHidden(value string, hidden bool) string {
+	if hidden {
+		return hiddenValue
+	}
+	return strconv.Quote(value)
+}
+
+// printRecord is a line of output in JSONOutput mode.
+type printRecord struct {
+	Line   int    `json:"line"`
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+func interpretPrint(n parser.PrintNode, e *env, scope *Scope) error {
+	vals := make([]string, len(n.Values))
+	anyHidden := false
+	for i, op := range n.Values {
+		val, hidden, err := resolveOperand(op, scope)
+		if err != nil {
+			return err
+		}
+		vals[i] = val
+		anyHidden = anyHidden || hidden
+	}
+	text := strings.Join(vals, " ")
+	if n.Formatted {
+		args := make([]any, len(vals)-1)
+		for i, val := range vals[1:] {
+			args[i] = val
+		}
+		text = fmt.Sprintf(vals[0], args...)
+	}
+
+	out, stream := e.stdout, "stdout"
+	if n.Stderr {
+		out, stream = e.stderr, "stderr"
+	}
+	if e.tracer != nil {
+		traced := text
+		if anyHidden {
+			traced = hiddenValue
+		}
+		e.trace(Event{Kind: Print, Stream: stream, Text: traced}.at(n))
+	}
+	if e.output == JSONOutput {
+		return json.NewEncoder(out).Encode(printRecord{
+			Line:   n.Pos.Line,
+			Stream: stream,
+			Text:   text,
+		})
+	}
+	_, err := fmt.Fprintln(out, text)
+	return err
+}
+
+// resolveOperand returns the value of a variable, or of a string literal after interpolation.
+// The returned bool reports whether the value is hidden, or was built from a hidden variable.
+func resolveOperand(op parser.Operand, scope *Scope) (string, bool, error) {
+	if op.IsLiteral {
+		return interpolate(op.Literal, scope, false)
+	}
+	val, err := scope.Get(op.Ident)
+	if err != nil {
+		return "", false, err
+	}
+	return val, scope.IsHidden(op.Ident), nil
+}
+
+func interpretInput(n parser.InputNode, e *env, scope *Scope) error {
+	if n.All {
+		data, err := io.ReadAll(e.stdin)
+		if err != nil {
+			return fmt.Errorf("error reading stdin: %w", err)
+		}
+		scope.Set(n.Ident, string(data))
+		return nil
+	}
+	if n.Prompt != "" {
+		prompt, _, err := interpolate(n.Prompt, scope, false)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(e.stdout, prompt); err != nil {
+			return err
+		}
+	}
+	line, err := e.stdin.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return fmt.Errorf("reached end of stdin while reading %s", n.Ident)
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error reading stdin: %w", err)
+	}
+	scope.Set(n.Ident, strings.TrimRight(line, "\r\n"))
+	return nil
+}
+
+func interpretRead(n parser.ReadNode, e *env, scope *Scope) error {
+	path, _, err := resolveOperand(n.Path, scope)
+	if err != nil {
+		return err
+	}
+	full, err := e.fs.resolve(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return fmt.Errorf("error reading '%s': %w", path, err)
+	}
+	e.trace(Event{Kind: FileAccess, Op: "read", Path: full, Bytes: len(data)}.at(n))
+	scope.Set(n.Ident, string(data))
+	return nil
+}
+
+func interpretWrite(n parser.WriteNode, e *env, scope *Scope) error {
+	path, _, err := resolveOperand(n.Path, scope)
+	if err != nil {
+		return err
+	}
+	value, _, err := resolveOperand(n.Value, scope)
+	if err != nil {
+		return err
+	}
+	full, err := e.fs.resolve(path)
+	if err != nil {
+		return err
+	}
+	verb := "write"
+	if n.Append {
+		verb = "append"
+	}
+	e.trace(Event{Kind: FileAccess, Op: verb, Path: full, Bytes: len(value), DryRun: e.dryRun}.at(n))
+	if e.dryRun {
+		_, err := fmt.Fprintf(e.stderr, "dry run: would %s %d bytes to '%s'\n", verb, len(value), full)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if n.Append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(full, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening '%s': %w", path, err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, value); err != nil {
+		return fmt.Errorf("error writing '%s': %w", path, err)
+	}
+	return nil
+}
+
+func interpretComment(_ parser.CommentNode, _ *Scope) error {
+	return nil
+}
+
+func interpretWhile(n parser.WhileNode, e *env, scope *Scope) ([]string, error) {
+	for iteration := 1; ; iteration++ {
+		resp, err := ask(e, n, "while", n.Condition, n.Using, scope)
+		if err != nil {
+			return nil, err
+		}
+		subScope := scope.SubScope()
+		if strings.Contains(resp, "EVALUATE_TRUE") {
+			e.trace(Event{Kind: LoopIteration, Iteration: iteration}.at(n))
+			returnVals, err := interpret(n.Statements, e, subScope)
+			if err != nil {
+				return nil, err
+			}
+			// If the loop body returned values, propagate them up
+			if returnVals != nil {
+				return returnVals, nil
+			}
+		} else if strings.Contains(resp, "EVALUATE_FALSE") {
+			return nil, nil
+		} else {
+			return nil, &ModelError{Statement: "while", Err: errors.New("llm did not decide")}
+		}
+	}
+}
+
+func interpretTry(n parser.TryNode, e *env, scope *Scope) ([]string, error) {
+	returnVals, err := interpret(n.Statements, e, scope.SubScope())
+	var stop *debuggerStop
+	if err != nil && n.CatchIdent != "" && !errors.As(err, &stop) {
+		e.trace(Event{Kind: ErrorCaught, Error: RedactedError(err)}.at(n))
+		catchScope := scope.SubScope()
+		var thrown *ThrownError
+		if errors.As(err, &thrown) && thrown.Hidden {
+			catchScope.SetHidden(n.CatchIdent, err.Error())
+		} else {
+			catchScope.Set(n.CatchIdent, err.Error())
+		}
+		catchScope.Set(n.CatchIdent+".kind", ErrorKind(err))
+		returnVals, err = interpret(n.CatchStatements, e, catchScope)
+	}
+	if n.HasFinally {
+		// An error or return in the finally block takes the place of whatever happened before it
+		finallyVals, finallyErr := interpret(n.FinallyStatements, e, scope.SubScope())
+		if finallyErr != nil {
+			return nil, finallyErr
+		}
+		if finallyVals != nil {
+			return finallyVals, nil
+		}
+	}
+	return returnVals, err
+}
+
+func interpretThrow(n parser.ThrowNode, scope *Scope) error {
+	message, hidden, err := resolveOperand(n.Value, scope)
+	if err != nil {
+		return err
+	}
+	return &ThrownError{Message: message, Hidden: hidden}
+}
+
+func interpretDel(n parser.DelNode, scope *Scope) error {
+	return scope.Del(n.Ident)
+}
+
+func interpretFuncDef(n parser.FuncDefNode, e *env, scope *Scope) error {
+	scope.SetFunc(n.Ident, Function{Def: n, Path: e.file})
+	return nil
+}
+
+func interpretReturn(n parser.ReturnNode, scope *Scope) ([]string, error) {
+	vals := make([]string, 0)
+	for _, ident := range n.Idents {
+		val, err := scope.Get(ident)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+func interpretRun(n parser.RunNode, e *env, scope *Scope) ([]string, error) {
+	fn, err := scope.GetFunc(n.FnIdent)
+	if err != nil {
+		return nil, err
+	}
+	if len(fn.Def.Args) != len(n.Inputs) {
+		return nil, &ArityError{Function: n.FnIdent, Expected: len(fn.Def.Args), Got: len(n.Inputs)}
+	}
+	args := make([]string, len(n.Inputs))
+	hidden := make([]bool, len(n.Inputs))
+	for i, input := range n.Inputs {
+		val, isHidden, err := resolveOperand(input, scope)
+		if err != nil {
+			return nil, err
+		}
+		args[i], hidden[i] = val, isHidden
+	}
+	returnVal, hideOutputs, err := call(n.FnIdent, fn, args, hidden, e, scope)
+	if err != nil {
+		return nil, err
+	}
+	if len(returnVal) != len(n.OutputIdents) {
+		return nil, fmt.Errorf("function provided %d outputs but caller provides %d", len(returnVal), len(n.OutputIdents))
+	}
+	for i, ident := range n.OutputIdents {
+		if hideOutputs {
+			scope.SetHidden(ident, returnVal[i])
+		} else {
+			scope.Set(ident, returnVal[i])
+		}
+	}
+	return nil, nil
+}
+
+// call runs fn with args, where hidden says which of the args are hidden. Functions defined in the running program can call
+// any function that caller can. It returns the function's return values, and whether they should be hidden.
+func call(name string, fn Function, args []string, hidden []bool, e *env, caller *Scope) ([]string, bool, error) {
+	if e.tracer == nil {
+		return callFunction(name, fn, args, hidden, e, caller)
+	}
+	traced := map[string]string{}
+	for i, arg := range fn.Def.Args {
+		traced[arg] = args[i]
+		if hidden[i] {
+			traced[arg] = hiddenValue
+		}
+	}
+	e.trace(Event{Kind: FunctionCall, Function: name, Args: traced})
+	start := time.Now()
+	returnVal, hideOutputs, err := callFunction(name, fn, args, hidden, e, caller)
+	ev := Event{Kind: FunctionReturn, Function: name, Returns: returnVal, DurationMS: durationMS(start)}
+	if hideOutputs {
+		ev.Returns = slices.Repeat([]string{hiddenValue}, len(returnVal))
+	}
+	if err != nil {
+		ev.Error = RedactedError(err)
+	}
+	e.trace(ev)
+	return returnVal, hideOutputs, err
+}
+
+func callFunction(name string, fn Function, args []string, hidden []bool, e *env, caller *Scope) ([]string, bool, error) {
+	if fn.Native != nil {
+		returnVal, err := fn.Native(args)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: %w", name, err)
+		}
+		// A builtin can't keep a secret, so anything it makes from a hidden argument is hidden too
+		return returnVal, slices.Contains(hidden, true), nil
+	}
+	freshScope := newScope(e.builtins)
+	for i, arg := range fn.Def.Args {
+		// Hidden arguments stay hidden inside the function
+		if hidden[i] {
+			freshScope.SetHidden(arg, args[i])
+		} else {
+			freshScope.Set(arg, args[i])
+		}
+	}
+	if fn.Module != nil {
+		freshScope.CopyFuncsFrom(fn.Module)
+	} else {
+		freshScope.CopyFuncsFrom(caller)
+	}
+	e.calls.push(&Frame{Function: name, Path: fn.Path})
+	defer e.calls.pop()
+	returnVal, err := interpret(fn.Def.Code, e, freshScope)
+	return returnVal, false, err
+}
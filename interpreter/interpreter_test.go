@@ -0,0 +1,60 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+func TestPromptVariables(t *testing.T) {
+	cases := []struct {
+		name      string
+		narrow    bool
+		statement string
+		user      string
+		want      string
+	}{
+		{name: "everything", statement: `let r = "Go";`, user: "Go", want: "doc,style,tone"},
+		{name: "interpolated", statement: `let r = "Summarise {doc}";`, user: "Summarise the doc", want: "style,tone"},
+		{name: "interpolated with angle brackets", statement: `let r = "Summarise <doc>";`, user: "Summarise the doc", want: "style,tone"},
+		{name: "using", statement: `let r = "Summarise {doc}" using style;`, user: "Summarise the doc", want: "style"},
+		{name: "using an interpolated variable", statement: `let r = "Summarise {doc}" using doc;`, user: "Summarise the doc", want: "doc"},
+		{name: "narrow", narrow: true, statement: `let r = "Summarise {doc}";`, user: "Summarise the doc", want: ""},
+		{name: "narrow with using", narrow: true, statement: `let r = "Go" using style, doc;`, user: "Go", want: "doc,style"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			code, err := parser.ParseSource(`const style = "short";
+const doc = "the doc";
+const tone = "x";
+const hidden key = "k";
+` + c.statement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracer := &eventRecorder{}
+			model := backend.NewReplayModel([]backend.Exchange{{User: c.user, Response: "ok"}})
+			interp, err := New(strings.NewReader(""), io.Discard, Options{Model: model, Tracer: tracer, Narrow: c.narrow})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := interp.Run(code, "", nil); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, ev := range tracer.events {
+				if ev.Kind == LLMRequest {
+					for _, v := range ev.Variables {
+						got = append(got, v.Name)
+					}
+				}
+			}
+			if strings.Join(got, ",") != c.want {
+				t.Errorf("expected the variables %q to be sent, got %q", c.want, got)
+			}
+		})
+	}
+}
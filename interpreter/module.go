@@ -0,0 +1,129 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/JoshPattman/hellm/lexer"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// module is a loaded hellm file. Its top-level functions and consts are exported to the programs that import it.
+type module struct {
+	scope  *Scope
+	consts []string
+	funcs  []string
+}
+
+// moduleCache holds every module loaded during a run, so that each one is only loaded once.
+type moduleCache struct {
+	loaded map[string]*module
+	// loading is the chain of modules currently being loaded, used to detect import cycles.
+	loading []string
+}
+
+func newModuleCache() *moduleCache {
+	return &moduleCache{loaded: map[string]*module{}}
+}
+
+func interpretImport(n parser.ImportNode, e *env, scope *Scope) error {
+	if !lexer.IsIdent(n.Alias) || strings.Contains(n.Alias, ".") {
+		return fmt.Errorf("import of '%s' needs a valid name, use: import \"%s\" as name;", n.Path, n.Path)
+	}
+	mod, err := loadModule(n.Path, e)
+	if err != nil {
+		return err
+	}
+	for _, name := range mod.consts {
+		val, err := mod.scope.Get(name)
+		if err != nil {
+			return err
+		}
+		if mod.scope.IsHidden(name) {
+			scope.SetHidden(n.Alias+"."+name, val)
+		} else {
+			scope.Set(n.Alias+"."+name, val)
+		}
+	}
+	for _, name := range mod.funcs {
+		fn, err := mod.scope.GetFunc(name)
+		if err != nil {
+			return err
+		}
+		fn.Module = mod.scope
+		scope.SetFunc(n.Alias+"."+name, fn)
+	}
+	return nil
+}
+
+// loadModule interprets the module at path (relative to the file doing the import), or returns it from the cache.
+// Paths starting with std/ load a standard library module from the binary instead.
+func loadModule(path string, e *env) (*module, error) {
+	full, dir := path, e.dir
+	if !IsStdModule(path) {
+		var err error
+		if !filepath.IsAbs(full) {
+			full = filepath.Join(e.dir, full)
+		}
+		full, err = filepath.Abs(full)
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(full)
+	}
+	if mod, ok := e.modules.loaded[full]; ok {
+		return mod, nil
+	}
+	if slices.Contains(e.modules.loading, full) {
+		chain := append(slices.Clone(e.modules.loading), full)
+		return nil, fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+	}
+	e.modules.loading = append(e.modules.loading, full)
+	defer func() { e.modules.loading = e.modules.loading[:len(e.modules.loading)-1] }()
+
+	src, err := readModule(path, full)
+	if err != nil {
+		return nil, err
+	}
+	code, err := parser.ParseSource(src)
+	if err != nil {
+		return nil, fmt.Errorf("error in module '%s': %w", path, err)
+	}
+
+	modEnv := *e
+	modEnv.file, modEnv.dir = full, dir
+	mod := &module{scope: newScope(e.builtins)}
+	e.calls.push(&Frame{Path: full})
+	defer e.calls.pop()
+	if _, err := interpret(code, &modEnv, mod.scope); err != nil {
+		return nil, fmt.Errorf("error in module '%s': %w", path, err)
+	}
+	for _, node := range code {
+		switch node := node.(type) {
+		case parser.ConstNode:
+			mod.consts = append(mod.consts, node.Ident)
+		case parser.FuncDefNode:
+			mod.funcs = append(mod.funcs, node.Ident)
+		}
+	}
+	e.modules.loaded[full] = mod
+	return mod, nil
+}
+
+// readModule reads the source of the module imported as path, which is found at full.
+func readModule(path, full string) (string, error) {
+	if IsStdModule(path) {
+		return readStdModule(path)
+	}
+	src, err := os.ReadFile(full)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("imported module '%s' does not exist", path)
+	} else if err != nil {
+		return "", fmt.Errorf("error reading module '%s': %w", path, err)
+	}
+	return string(src), nil
+}
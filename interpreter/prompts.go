@@ -0,0 +1,89 @@
+package interpreter
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+// promptNames are the templates used by the interpreter, in the order they are dumped.
+var promptNames = []string{"let", "if", "while", "assert", "scope"}
+
+// Prompts are the text/templates used to build the system prompts sent to the model.
+type Prompts struct {
+	tmpl *template.Template
+}
+
+// PromptVariable is a variable made available to a prompt template.
+type PromptVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// promptData is what the let, if, while and assert templates are executed with.
+type promptData struct {
+	Variables []PromptVariable
+}
+
+// DefaultPrompts returns the prompts built into the binary.
+func DefaultPrompts() *Prompts {
+	tmpl := template.New("prompts")
+	for _, name := range promptNames {
+		src, err := defaultPromptFiles.ReadFile("prompts/" + name + ".tmpl")
+		if err != nil {
+			panic(err)
+		}
+		template.Must(tmpl.New(name).Parse(string(src)))
+	}
+	return &Prompts{tmpl: tmpl}
+}
+
+// Override replaces any of the prompts that are redefined in the file at path.
+// The file should contain one {{define "name"}}...{{end}} block for each prompt it overrides.
+func (p *Prompts) Override(path string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading prompt file '%s': %w", path, err)
+	}
+	tmpl, err := p.tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	if _, err := tmpl.New(path).Parse(string(src)); err != nil {
+		return fmt.Errorf("error parsing prompt file '%s': %w", path, err)
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Name() != path && t.Name() != "prompts" && !slices.Contains(promptNames, t.Name()) {
+			return fmt.Errorf("prompt file '%s' defines unknown prompt '%s'", path, t.Name())
+		}
+	}
+	p.tmpl = tmpl
+	return nil
+}
+
+// Render executes the named prompt with the given variables in scope.
+func (p *Prompts) Render(name string, vars []PromptVariable) (string, error) {
+	buf := &strings.Builder{}
+	if err := p.tmpl.ExecuteTemplate(buf, name, promptData{Variables: vars}); err != nil {
+		return "", fmt.Errorf("error rendering %s prompt: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Dump writes the source of every active prompt to w, in the format accepted by Override.
+func (p *Prompts) Dump(w io.Writer) error {
+	for _, name := range promptNames {
+		src := p.tmpl.Lookup(name).Tree.Root.String()
+		if _, err := fmt.Fprintf(w, "{{define %q}}%s{{end}}\n\n", name, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
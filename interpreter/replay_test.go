@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+// countingModel answers each question with how many it has been asked.
+type countingModel struct {
+	calls int
+}
+
+func (m *countingModel) Tokens() (int, int) {
+	return 0, 0
+}
+
+func (m *countingModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	m.calls++
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: strings.Repeat("x", m.calls)}, jpf.Usage{}, nil
+}
+
+// TestRecordReplay records a run with several variables in scope, then checks that every replay of it finds every answer.
+func TestRecordReplay(t *testing.T) {
+	code, err := parser.ParseSource(`
+const a = "apple";
+const b = "banana";
+const c = "cherry";
+const d = "date";
+const e = "elderberry";
+let f = "Pick a fruit";
+let g = "Pick another fruit";
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := backend.NewRecorder(&countingModel{})
+	interp, err := New(strings.NewReader(""), io.Discard, Options{Model: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	exchanges := recorder.Exchanges()
+	for i := range 20 {
+		interp, err := New(strings.NewReader(""), io.Discard, Options{Model: backend.NewReplayModel(exchanges)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := interp.Run(code, "", nil); err != nil {
+			t.Fatalf("replay %d failed: %v", i, err)
+		}
+		if f, _ := interp.Scope().Get("f"); f != "x" {
+			t.Fatalf("replay %d: expected f to be x, got %q", i, f)
+		}
+		if g, _ := interp.Scope().Get("g"); g != "xx" {
+			t.Fatalf("replay %d: expected g to be xx, got %q", i, g)
+		}
+	}
+}
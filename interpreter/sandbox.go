@@ -0,0 +1,79 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandbox restricts file access to the files inside a root directory.
+type sandbox struct {
+	root string
+}
+
+func newSandbox(root string) (*sandbox, error) {
+	if root == "" {
+		return nil, nil
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filesystem root '%s': %w", root, err)
+	}
+	return &sandbox{root: abs}, nil
+}
+
+// resolve turns a path from a script (relative to the root, or absolute) into an absolute path,
+// returning an error if it is outside the root. Symlinks are followed so they cannot be used to escape.
+func (s *sandbox) resolve(path string) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("file access is disabled (no filesystem root is set)")
+	}
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(s.root, full)
+	}
+	full = filepath.Clean(full)
+	// Follow symlinks on as much of the path as already exists
+	real, rest := full, ""
+	for links := 0; ; {
+		resolved, err := filepath.EvalSymlinks(real)
+		if err == nil {
+			real = filepath.Join(resolved, rest)
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		// A dangling symlink does not exist as far as EvalSymlinks is concerned, but opening a path through it follows it, so follow it here too
+		if info, err := os.Lstat(real); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if links++; links > 255 {
+				return "", fmt.Errorf("too many symlinks in '%s'", path)
+			}
+			target, err := os.Readlink(real)
+			if err != nil {
+				return "", err
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(real), target)
+			}
+			real = filepath.Clean(target)
+			continue
+		}
+		parent := filepath.Dir(real)
+		if parent == real {
+			break
+		}
+		rest = filepath.Join(filepath.Base(real), rest)
+		real = parent
+	}
+	rel, err := filepath.Rel(s.root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("access to '%s' denied: it is outside the filesystem root '%s'", path, s.root)
+	}
+	return real, nil
+}
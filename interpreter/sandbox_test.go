@@ -0,0 +1,108 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// sandboxDirs makes a root directory for a sandbox and a directory outside it, with symlinks from the root to both.
+func sandboxDirs(t *testing.T) (root, outside string) {
+	dir := t.TempDir()
+	root, outside = filepath.Join(dir, "root"), filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "sub"), outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(root, "real.txt"), filepath.Join(outside, "secret.txt")} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"file_out":     filepath.Join(outside, "secret.txt"),
+		"file_in":      "real.txt",
+		"dangling_out": filepath.Join(outside, "pwned.txt"),
+		"dangling_in":  "sub/new.txt",
+		"dangling_rel": "../outside/pwned.txt",
+		"dir_out":      outside,
+		"dir_in":       "sub",
+		"dir_gone":     filepath.Join(outside, "missing"),
+		"chain":        "dangling_out",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("cannot make symlinks: %v", err)
+		}
+	}
+	return root, outside
+}
+
+func TestSandboxResolve(t *testing.T) {
+	root, outside := sandboxDirs(t)
+	sb, err := newSandbox(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = sb.root
+	cases := []struct {
+		path string
+		// want is where the path should resolve to, relative to the root, or empty if it should be denied.
+		want string
+	}{
+		{path: "real.txt", want: "real.txt"},
+		{path: "new/dir/file.txt", want: "new/dir/file.txt"},
+		{path: "../outside/secret.txt"},
+		{path: filepath.Join(outside, "secret.txt")},
+		{path: "file_out"},
+		{path: "file_in", want: "real.txt"},
+		{path: "dangling_out"},
+		{path: "dangling_rel"},
+		{path: "dangling_in", want: "sub/new.txt"},
+		{path: "chain"},
+		{path: "dir_out/new.txt"},
+		{path: "dir_out/secret.txt"},
+		{path: "dir_in/new.txt", want: "sub/new.txt"},
+		{path: "dir_gone/a/b.txt"},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			got, err := sb.resolve(c.path)
+			if c.want == "" {
+				if err == nil {
+					t.Fatalf("expected access to be denied, but it resolved to %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(c.want)); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestSandboxWriteThroughDanglingSymlink(t *testing.T) {
+	root, outside := sandboxDirs(t)
+	code, err := parser.ParseSource(`write "pwned" to "dangling_out";`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := New(strings.NewReader(""), io.Discard, Options{FSRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, "", nil); err == nil || !strings.Contains(err.Error(), "outside the filesystem root") {
+		t.Fatalf("expected the write to be denied, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file to be written outside the root, got %v", err)
+	}
+}
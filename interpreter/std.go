@@ -0,0 +1,145 @@
+package interpreter
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+//go:embed std/*.hl
+var stdFiles embed.FS
+
+// stdPrefix marks an import of one of the standard library modules built into the binary, e.g. import "std/text";
+const stdPrefix = "std/"
+
+// IsStdModule reports whether importPath names a standard library module rather than a file.
+func IsStdModule(importPath string) bool {
+	return strings.HasPrefix(importPath, stdPrefix)
+}
+
+// readStdModule returns the source of the standard library module imported as importPath.
+func readStdModule(importPath string) (string, error) {
+	src, err := stdFiles.ReadFile(strings.TrimSuffix(importPath, ".hl") + ".hl")
+	if err != nil {
+		return "", fmt.Errorf("there is no standard library module '%s' (see hellm doc std)", importPath)
+	}
+	return string(src), nil
+}
+
+// ModuleDoc describes the functions a module exports.
+type ModuleDoc struct {
+	Name  string
+	Doc   string
+	Funcs []FuncDoc
+}
+
+// FuncDoc describes how to call a function with run.
+type FuncDoc struct {
+	Name    string
+	Args    []string
+	Returns []string
+	Doc     string
+}
+
+// Usage is an example run statement calling the function from a module imported as alias.
+func (f FuncDoc) Usage(alias string) string {
+	usage := "run "
+	if len(f.Returns) > 0 {
+		usage += strings.Join(f.Returns, " ") + " = "
+	}
+	usage += alias + "." + f.Name
+	if len(f.Args) > 0 {
+		usage += " " + strings.Join(f.Args, " ")
+	}
+	return usage + ";"
+}
+
+// DocModule documents the top-level functions of a parsed module.
+// A com statement at the start of the module (after any # or // comments) documents the module, and one directly before a fn documents that function.
+func DocModule(name string, code []parser.ASTNode) ModuleDoc {
+	doc := ModuleDoc{Name: name}
+	pending := ""
+	first := true
+	for _, node := range code {
+		switch n := node.(type) {
+		case parser.SourceCommentNode:
+			continue
+		case parser.CommentNode:
+			if first {
+				doc.Doc = n.Comment
+			} else {
+				pending = n.Comment
+			}
+			first = false
+			continue
+		case parser.FuncDefNode:
+			doc.Funcs = append(doc.Funcs, FuncDoc{
+				Name:    n.Ident,
+				Args:    n.Args,
+				Returns: funcReturns(n),
+				Doc:     pending,
+			})
+		}
+		pending = ""
+		first = false
+	}
+	return doc
+}
+
+// funcReturns finds the names a function returns, from the first return statement in it.
+func funcReturns(fn parser.FuncDefNode) []string {
+	var returns []string
+	parser.WalkNodes(fn.Code, func(node parser.ASTNode) {
+		if ret, ok := node.(parser.ReturnNode); ok && returns == nil {
+			returns = ret.Idents
+		}
+	})
+	return returns
+}
+
+// StdDocs parses and documents every standard library module.
+func StdDocs() ([]ModuleDoc, error) {
+	files, err := fs.Glob(stdFiles, "std/*.hl")
+	if err != nil {
+		return nil, err
+	}
+	docs := []ModuleDoc{}
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".hl")
+		src, err := readStdModule(name)
+		if err != nil {
+			return nil, err
+		}
+		code, err := parser.ParseSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("error in module '%s': %w", name, err)
+		}
+		docs = append(docs, DocModule(name, code))
+	}
+	return docs, nil
+}
+
+// WriteModuleDocs writes the documentation for each module to w.
+func WriteModuleDocs(w io.Writer, docs []ModuleDoc) {
+	for i, doc := range docs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		alias := path.Base(doc.Name)
+		fmt.Fprintf(w, "import %q;\n", doc.Name)
+		if doc.Doc != "" {
+			fmt.Fprintf(w, "    %s\n", doc.Doc)
+		}
+		for _, fn := range doc.Funcs {
+			fmt.Fprintf(w, "\n    %s\n", fn.Usage(alias))
+			if fn.Doc != "" {
+				fmt.Fprintf(w, "        %s\n", fn.Doc)
+			}
+		}
+	}
+}
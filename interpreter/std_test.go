@@ -0,0 +1,193 @@
+package interpreter
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// stdCalls calls every standard library function, with the question each should send the model.
+// The arguments are not part of the question, they are sent as variables.
+var stdCalls = []struct {
+	module string
+	fn     string
+	args   []string
+	user   string
+}{
+	{
+		module: "std/text", fn: "summarize", args: []string{"Dogs bark."},
+		user: "A summary of the variable text in at most three sentences. Keep the key facts, names and numbers, and do not add anything that is not in the text. Reply with only the summary.",
+	},
+	{
+		module: "std/text", fn: "translate", args: []string{"Hello", "French"},
+		user: "The variable text translated into the language named in the variable language. Keep the meaning, tone and formatting of the original. Reply with only the translation.",
+	},
+	{
+		module: "std/text", fn: "rewrite", args: []string{"Hi", "pirate"},
+		user: "The variable text rewritten in the style described in the variable style. Keep the meaning of the original. Reply with only the rewritten text.",
+	},
+	{
+		module: "std/text", fn: "bullet_points", args: []string{"a and b"},
+		user: "The main points of the variable text as a list, one point per line, each line starting with a dash and a space. Reply with only the list.",
+	},
+	{
+		module: "std/classify", fn: "classify", args: []string{"I love it", "good,bad"},
+		user: "Exactly one label from the comma separated list in the variable labels, whichever best describes the variable text. Reply with only the label, spelled exactly as it is in the list.",
+	},
+	{
+		module: "std/classify", fn: "sentiment", args: []string{"I love it"},
+		user: "The sentiment of the variable text, as exactly one of the words positive, negative or neutral, in lowercase. Reply with only that word.",
+	},
+	{
+		module: "std/classify", fn: "language", args: []string{"Guten Tag"},
+		user: "The English name of the language that the variable text is written in, e.g. German. Reply with only the name of the language.",
+	},
+	{
+		module: "std/extract", fn: "extract_json", args: []string{"Bob is 30", "name, age"},
+		user: "A JSON object containing the information from the variable text, with the fields described in the variable schema. Use null for any field that the text does not mention. Reply with only the JSON, without a code block.",
+	},
+	{
+		module: "std/extract", fn: "extract_list", args: []string{"a@b.com and c@d.com", "email address"},
+		user: "Every item of the kind described in the variable what that is mentioned in the variable text, one per line, in the order they appear. Reply with only the items, or nothing at all if there are none.",
+	},
+	{
+		module: "std/extract", fn: "answer", args: []string{"The sky is blue.", "What colour is the sky?"},
+		user: "The answer to the question in the variable question, using only the information in the variable text. If the text does not contain the answer, reply with exactly: The text does not say.",
+	},
+}
+
+func TestStdFunctions(t *testing.T) {
+	for _, c := range stdCalls {
+		t.Run(c.module+"."+c.fn, func(t *testing.T) {
+			model := backend.NewReplayModel([]backend.Exchange{{User: c.user, Response: "the answer"}})
+			tracer := &eventRecorder{}
+			interp, err := New(strings.NewReader(""), io.Discard, Options{Model: model, Tracer: tracer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := parser.ParseSource(`import "` + c.module + `" as m;`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := interp.Run(code, "", nil); err != nil {
+				t.Fatal(err)
+			}
+			outputs, err := interp.Call("m."+c.fn, c.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outputs) != 1 || outputs[0] != "the answer" {
+				t.Errorf("expected the model's answer to be returned, got %q", outputs)
+			}
+			sent := []string{}
+			for _, ev := range tracer.events {
+				if ev.Kind == LLMRequest {
+					for _, v := range ev.Variables {
+						sent = append(sent, v.Value)
+					}
+				}
+			}
+			if !sameItems(sent, c.args) {
+				t.Errorf("expected exactly the arguments %q to be sent as variables, got %q", c.args, sent)
+			}
+		})
+	}
+}
+
+// sameItems reports whether a and b hold the same strings, in any order.
+func sameItems(a, b []string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(a)), slices.Sorted(slices.Values(b)))
+}
+
+func TestStdDocs(t *testing.T) {
+	docs, err := StdDocs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tested := map[string]bool{}
+	for _, c := range stdCalls {
+		tested[c.module+"."+c.fn] = true
+	}
+	for _, doc := range docs {
+		if doc.Doc == "" {
+			t.Errorf("module %s has no documentation", doc.Name)
+		}
+		for _, fn := range doc.Funcs {
+			if fn.Doc == "" {
+				t.Errorf("function %s.%s has no documentation", doc.Name, fn.Name)
+			}
+			if !tested[doc.Name+"."+fn.Name] {
+				t.Errorf("function %s.%s is not called by TestStdFunctions", doc.Name, fn.Name)
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	WriteModuleDocs(buf, docs)
+	golden := filepath.Join("testdata", "std_docs.txt")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("documentation does not match %s (run go test -update if the change is intended)\ngot:\n%s", golden, buf.String())
+	}
+}
+
+func TestDocModule(t *testing.T) {
+	code, err := parser.ParseSource(`# licence header
+com "The module.";
+
+com "Says hi.";
+fn hi name {
+    let greeting = "Hi {name}";
+    return greeting;
+}
+
+com "Not attached to anything.";
+let x = "1";
+// between the doc and the function
+fn bye {
+    return;
+}
+com "Attached to later.";
+# a comment does not detach it
+fn later a b {
+    run c d = hi a;
+    return c d;
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := DocModule("mod", code)
+	if doc.Doc != "The module." {
+		t.Errorf("expected the module doc to be %q, got %q", "The module.", doc.Doc)
+	}
+	want := []string{
+		"run greeting = mod.hi name; // Says hi.",
+		"run mod.bye; // ",
+		"run c d = mod.later a b; // Attached to later.",
+	}
+	got := []string{}
+	for _, fn := range doc.Funcs {
+		got = append(got, fn.Usage("mod")+" // "+fn.Doc)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected functions\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
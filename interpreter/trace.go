@@ -0,0 +1,168 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// Tracer is told about what the interpreter does as it runs.
+type Tracer interface {
+	Trace(Event)
+}
+
+// EventKind is the kind of thing an Event records. The fields each kind sets are documented in docs/trace.md.
+type EventKind string
+
+const (
+	// StatementEnter is traced just before a statement runs.
+	StatementEnter EventKind = "statement_enter"
+	// StatementExit is traced when a statement has finished, with Error set if it failed.
+	StatementExit EventKind = "statement_exit"
+	// LLMRequest is traced just before a let, if or while statement asks the model something.
+	LLMRequest EventKind = "llm_request"
+	// LLMResponse is traced when the model has answered, or failed to.
+	LLMResponse EventKind = "llm_response"
+	// Branch is traced when an if statement has decided which way to go.
+	Branch EventKind = "branch"
+	// LoopIteration is traced each time a while statement decides to run its body again.
+	LoopIteration EventKind = "loop_iteration"
+	// ErrorCaught is traced when a try statement catches an error, with the error it caught.
+	ErrorCaught EventKind = "error_caught"
+	// FunctionCall is traced when a function is called with run.
+	FunctionCall EventKind = "function_call"
+	// FunctionReturn is traced when a called function has finished, with Error set if it failed.
+	FunctionReturn EventKind = "function_return"
+	// Print is traced when a print statement writes a line, with the stream it wrote to and what it wrote.
+	Print EventKind = "print"
+	// FileAccess is traced when a read, write or append statement touches a file.
+	FileAccess EventKind = "file_access"
+	// RunError is traced when a run or call fails, with the error it failed with.
+	RunError EventKind = "error"
+)
+
+// hiddenValue replaces the value of hidden variables in traces.
+const hiddenValue = "<hidden>"
+
+// Event is something that happened while interpreting a program.
+// Only the fields that make sense for its Kind are set.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	Time time.Time `json:"time"`
+	// File is the file the statement the event is about is in, such as an imported module. It is empty for programs that weren't loaded from a file.
+	File string `json:"file,omitempty"`
+	// Line and Col are where the statement the event is about starts.
+	Line int `json:"line,omitempty"`
+	Col  int `json:"col,omitempty"`
+	// Statement is the first line of the formatted statement.
+	Statement string `json:"statement,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// DurationMS is how long a statement, function call or model call took.
+	DurationMS float64 `json:"duration_ms,omitempty"`
+
+	// Prompt is the system prompt sent to the model, and Text is the statement's text after interpolation, or what a print statement wrote.
+	Prompt string `json:"prompt,omitempty"`
+	Text   string `json:"text,omitempty"`
+	// Variables are the variables included in the prompt.
+	Variables    []PromptVariable `json:"variables,omitempty"`
+	Response     string           `json:"response,omitempty"`
+	InputTokens  int              `json:"input_tokens,omitempty"`
+	OutputTokens int              `json:"output_tokens,omitempty"`
+	Cost         float64          `json:"cost,omitempty"`
+
+	// Taken is which way an if statement went.
+	Taken *bool `json:"taken,omitempty"`
+	// Iteration counts the runs of a while statement's body, starting at 1.
+	Iteration int `json:"iteration,omitempty"`
+
+	// Stream is the stream a print statement wrote to: stdout or stderr.
+	Stream string `json:"stream,omitempty"`
+
+	Function string            `json:"function,omitempty"`
+	Args     map[string]string `json:"args,omitempty"`
+	Returns  []string          `json:"returns,omitempty"`
+
+	// Op is read, write or append, and Path is the file after resolving it inside the filesystem root.
+	Op     string `json:"op,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Bytes  int    `json:"bytes,omitempty"`
+	DryRun bool   `json:"dry_run,omitempty"`
+}
+
+// at sets where in the source the event happened, from the statement n.
+func (ev Event) at(n parser.ASTNode) Event {
+	pos := n.Position()
+	ev.Line, ev.Col = pos.Line, pos.Col
+	ev.Statement = statementSummary(n)
+	return ev
+}
+
+// statementSummary is the first line of a formatted statement, so that blocks aren't repeated in full.
+func statementSummary(n parser.ASTNode) string {
+	summary, _, _ := strings.Cut(strings.TrimSpace(n.Format("")), "\n")
+	return summary
+}
+
+func (e *env) trace(ev Event) {
+	if e.tracer == nil {
+		return
+	}
+	ev.Time = time.Now()
+	if ev.Line != 0 {
+		ev.File = e.calls.file()
+	}
+	e.tracer.Trace(ev)
+}
+
+func durationMS(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// JSONLTracer writes each event to a writer as a line of JSON.
+type JSONLTracer struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func NewJSONLTracer(w io.Writer) *JSONLTracer {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONLTracer{enc: enc}
+}
+
+func (t *JSONLTracer) Trace(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(ev)
+	}
+}
+
+// Err returns the first error writing the trace, if there was one.
+func (t *JSONLTracer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+// ReadTrace reads the events a JSONLTracer wrote to r.
+func ReadTrace(r io.Reader) ([]Event, error) {
+	var events []Event
+	dec := json.NewDecoder(r)
+	for {
+		var ev Event
+		err := dec.Decode(&ev)
+		if err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading event %d of trace: %w", len(events)+1, err)
+		}
+		events = append(events, ev)
+	}
+}
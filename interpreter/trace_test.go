@@ -0,0 +1,60 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/parser"
+)
+
+// eventRecorder is a tracer that keeps every event.
+type eventRecorder struct {
+	events []Event
+}
+
+func (r *eventRecorder) Trace(ev Event) {
+	r.events = append(r.events, ev)
+}
+
+func TestTraceFile(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "m.hl")
+	main := filepath.Join(dir, "main.hl")
+	if err := os.WriteFile(module, []byte("fn shout x {\n    let y = \"Shout {x}\";\n    return y;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, err := parser.ParseSource("import \"m.hl\" as m;\nrun z = m.shout \"hey\";\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := &eventRecorder{}
+	model := backend.NewReplayModel([]backend.Exchange{{User: "Shout hey", Response: "HEY"}})
+	interp, err := New(strings.NewReader(""), io.Discard, Options{Model: model, Tracer: tracer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Run(code, main, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, ev := range tracer.events {
+		if ev.Kind == StatementEnter || ev.Kind == LLMRequest {
+			got = append(got, string(ev.Kind)+" "+filepath.Base(ev.File)+":"+ev.Statement)
+		}
+	}
+	want := []string{
+		`statement_enter main.hl:import "m.hl";`,
+		`statement_enter m.hl:fn shout x {`,
+		`statement_enter main.hl:run z = m.shout "hey";`,
+		`statement_enter m.hl:let y = "Shout {x}";`,
+		`llm_request m.hl:let y = "Shout {x}";`,
+		`statement_enter m.hl:return y;`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected events\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,40 @@
+package lexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// addExamples seeds the corpus of f with the example programs.
+func addExamples(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("..", "examples", "*.hl"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+}
+
+func FuzzLex(f *testing.F) {
+	addExamples(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens, err := Lex(src)
+		if err != nil {
+			return
+		}
+		var last Pos
+		for _, tok := range tokens {
+			pos := tok.Position()
+			if pos.Line < last.Line || (pos.Line == last.Line && pos.Col <= last.Col) {
+				t.Fatalf("token %s at %s does not come after the token at %s", FormatLexToken(tok), pos, last)
+			}
+			last = pos
+		}
+	})
+}
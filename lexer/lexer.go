@@ -0,0 +1,552 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PatternMatchable is anything that the parser can match against a sequence of tokens.
+// Copy consumes tokens from the start of the slice, returning how many it used and whether it matched.
+type PatternMatchable interface {
+	Copy(tokens []LexToken) (int, bool)
+}
+
+type LexToken interface {
+	PatternMatchable
+	Position() Pos
+	setPosition(Pos)
+}
+
+// Pos is a position in a source file. Lines and columns start at 1.
+type Pos struct {
+	Line int
+	Col  int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+func (p Pos) Position() Pos {
+	return p
+}
+
+func (p *Pos) setPosition(pos Pos) {
+	*p = pos
+}
+
+// advance returns the position after reading text from p.
+func (p Pos) advance(text string) Pos {
+	for _, c := range text {
+		if c == '\n' {
+			p.Line++
+			p.Col = 1
+		} else {
+			p.Col++
+		}
+	}
+	return p
+}
+
+type LetLexToken struct{ Pos }
+type ConstLexToken struct{ Pos }
+type UseLexToken struct{ Pos }
+type FnLexToken struct{ Pos }
+type IdentLexToken struct {
+	Pos
+	Name string
+}
+type StringLexToken struct {
+	Pos
+	Value string
+}
+type OpenBraceLexToken struct{ Pos }
+type CloseBraceLexToken struct{ Pos }
+type SemiColonLexToken struct{ Pos }
+type EqLexToken struct{ Pos }
+type IfLexToken struct{ Pos }
+type WhileLexToken struct{ Pos }
+type ElseLexToken struct{ Pos }
+type PrintLexToken struct{ Pos }
+type CommentLexToken struct{ Pos }
+type DelLexToken struct{ Pos }
+type RunLexToken struct{ Pos }
+type ReturnLexToken struct{ Pos }
+type CommaLexToken struct{ Pos }
+
+// SourceCommentLexToken is a # or // line comment, or a /* */ block comment.
+// Text is the whole comment, including the characters that start and end it, so that it can be written back out as it was.
+type SourceCommentLexToken struct {
+	Pos
+	Text string
+	// Trailing is set if the comment is on the same line as the token before it, rather than on a line of its own.
+	Trailing bool
+}
+
+func (t *LetLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*LetLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *ConstLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*ConstLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *UseLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*UseLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *FnLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*FnLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *IdentLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	otherT, ok := tokens[0].(*IdentLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Name = otherT.Name
+	t.Pos = otherT.Pos
+	return 1, true
+}
+func (t *SourceCommentLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	otherT, ok := tokens[0].(*SourceCommentLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Text = otherT.Text
+	t.Trailing = otherT.Trailing
+	t.Pos = otherT.Pos
+	return 1, true
+}
+func (t *StringLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	otherT, ok := tokens[0].(*StringLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Value = otherT.Value
+	t.Pos = otherT.Pos
+	return 1, true
+}
+func (t *OpenBraceLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*OpenBraceLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *CloseBraceLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*CloseBraceLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *IfLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*IfLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *PrintLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*PrintLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *EqLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*EqLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *SemiColonLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*SemiColonLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *ElseLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*ElseLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *WhileLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*WhileLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *CommentLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*CommentLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *DelLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*DelLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *RunLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*RunLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *ReturnLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*ReturnLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+func (t *CommaLexToken) Copy(tokens []LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	_, ok := tokens[0].(*CommaLexToken)
+	if !ok {
+		return 0, false
+	}
+	t.Pos = tokens[0].Position()
+	return 1, true
+}
+
+// keywords maps each keyword to a function that makes its token. Any other word is an identifier,
+// including the words of newer statements (e.g. read, try, assert), which the parser only treats as keywords at the start of a statement.
+var keywords = map[string]func() LexToken{
+	"let":    func() LexToken { return &LetLexToken{} },
+	"const":  func() LexToken { return &ConstLexToken{} },
+	"use":    func() LexToken { return &UseLexToken{} },
+	"fn":     func() LexToken { return &FnLexToken{} },
+	"print":  func() LexToken { return &PrintLexToken{} },
+	"if":     func() LexToken { return &IfLexToken{} },
+	"while":  func() LexToken { return &WhileLexToken{} },
+	"else":   func() LexToken { return &ElseLexToken{} },
+	"com":    func() LexToken { return &CommentLexToken{} },
+	"del":    func() LexToken { return &DelLexToken{} },
+	"run":    func() LexToken { return &RunLexToken{} },
+	"return": func() LexToken { return &ReturnLexToken{} },
+}
+
+// Lex splits input into tokens. Comments are kept as SourceCommentLexTokens, so that the formatter can write them back out.
+func Lex(input string) ([]LexToken, error) {
+	l := &lexState{src: input, pos: Pos{Line: 1, Col: 1}}
+	tokens := []LexToken{}
+	for {
+		line := l.pos.Line
+		l.skipSpace()
+		if l.offset == len(l.src) {
+			return tokens, nil
+		}
+		pos := l.pos
+		token, err := l.next()
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("error lexing input at %s", pos), err)
+		}
+		if comment, ok := token.(*SourceCommentLexToken); ok {
+			comment.Trailing = len(tokens) > 0 && pos.Line == line
+		}
+		token.setPosition(pos)
+		tokens = append(tokens, token)
+	}
+}
+
+// lexState is how far the lexer has got through its source.
+type lexState struct {
+	src    string
+	offset int
+	pos    Pos
+}
+
+// rest is the source that is still to be lexed.
+func (l *lexState) rest() string {
+	return l.src[l.offset:]
+}
+
+// skip moves past the next n bytes of the source, returning them.
+func (l *lexState) skip(n int) string {
+	text := l.src[l.offset : l.offset+n]
+	l.offset += n
+	l.pos = l.pos.advance(text)
+	return text
+}
+
+func (l *lexState) skipSpace() {
+	rest := l.rest()
+	l.skip(len(rest) - len(strings.TrimLeft(rest, " \t\n\r")))
+}
+
+// next reads the token at the start of the rest of the source.
+func (l *lexState) next() (LexToken, error) {
+	rest := l.rest()
+	switch {
+	case rest[0] == '"':
+		return l.readString()
+	case rest[0] == '#' || strings.HasPrefix(rest, "//"):
+		return l.readLineComment(), nil
+	case strings.HasPrefix(rest, "/*"):
+		return l.readBlockComment()
+	}
+	switch rest[0] {
+	case ';':
+		l.skip(1)
+		return &SemiColonLexToken{}, nil
+	case ',':
+		l.skip(1)
+		return &CommaLexToken{}, nil
+	case '=':
+		l.skip(1)
+		return &EqLexToken{}, nil
+	case '{':
+		l.skip(1)
+		return &OpenBraceLexToken{}, nil
+	case '}':
+		l.skip(1)
+		return &CloseBraceLexToken{}, nil
+	}
+	if n := identLen(rest); n > 0 {
+		word := l.skip(n)
+		if keyword, ok := keywords[word]; ok {
+			return keyword(), nil
+		}
+		return &IdentLexToken{Name: word}, nil
+	}
+	return nil, fmt.Errorf("unrecognized token at start of '%s'", preview(rest))
+}
+
+func (l *lexState) readString() (LexToken, error) {
+	end := strings.IndexByte(l.rest()[1:], '"')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated string '%s'", preview(l.rest()))
+	}
+	text := l.skip(end + 2) // +2 for both quotes
+	return &StringLexToken{Value: text[1 : len(text)-1]}, nil
+}
+
+func (l *lexState) readLineComment() LexToken {
+	rest := l.rest()
+	end := strings.IndexByte(rest, '\n')
+	if end < 0 {
+		end = len(rest)
+	}
+	return &SourceCommentLexToken{Text: strings.TrimRight(l.skip(end), " \t\r")}
+}
+
+func (l *lexState) readBlockComment() (LexToken, error) {
+	end := strings.Index(l.rest()[2:], "*/")
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated block comment '%s'", preview(l.rest()))
+	}
+	return &SourceCommentLexToken{Text: l.skip(end + 4)}, nil // +4 for the /* and */
+}
+
+// preview is the start of s, for showing where an error is.
+func preview(s string) string {
+	const length = 20
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+	return string([]rune(s)[:length]) + "..."
+}
+
+// identLen is the length in bytes of the word at the start of s, which may be qualified with a module name (e.g. strings.summarize).
+func identLen(s string) int {
+	n := 0
+	for n < len(s) {
+		c, size := utf8.DecodeRuneInString(s[n:])
+		// A dot is only part of the identifier if it joins two names
+		if c == '.' && n > 0 {
+			if next, _ := utf8.DecodeRuneInString(s[n+size:]); isIdentRune(next) {
+				n += size
+				continue
+			}
+		}
+		if !isIdentRune(c) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func isIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
+
+func FormatLexToken(token LexToken) string {
+	const (
+		purple = "\033[35m"
+		yellow = "\033[33m"
+		green  = "\033[32m"
+		grey   = "\033[90m"
+		reset  = "\033[0m"
+	)
+
+	switch t := token.(type) {
+	case *LetLexToken:
+		return purple + "let" + reset
+	case *ConstLexToken:
+		return purple + "const" + reset
+	case *UseLexToken:
+		return purple + "use" + reset
+	case *FnLexToken:
+		return purple + "fn" + reset
+	case *IdentLexToken:
+		return yellow + t.Name + reset
+	case *StringLexToken:
+		return green + fmt.Sprintf("\"%s\"", t.Value) + reset
+	case *OpenBraceLexToken:
+		return "{"
+	case *CloseBraceLexToken:
+		return "}"
+	case *SemiColonLexToken:
+		return ";"
+	case *EqLexToken:
+		return "="
+	case *IfLexToken:
+		return purple + "if" + reset
+	case *ElseLexToken:
+		return purple + "else" + reset
+	case *WhileLexToken:
+		return purple + "while" + reset
+	case *PrintLexToken:
+		return purple + "print" + reset
+	case *CommentLexToken:
+		return purple + "com" + reset
+	case *DelLexToken:
+		return purple + "del" + reset
+	case *RunLexToken:
+		return purple + "run" + reset
+	case *ReturnLexToken:
+		return purple + "return" + reset
+	case *CommaLexToken:
+		return ","
+	case *SourceCommentLexToken:
+		return grey + t.Text + reset
+	default:
+		panic(fmt.Sprintf("unknown token type: %T", t))
+	}
+}
+
+func FormatLexTokens(tokens []LexToken) string {
+	formatted := make([]string, len(tokens))
+	for i, token := range tokens {
+		formatted[i] = FormatLexToken(token)
+	}
+	return strings.Join(formatted, " ")
+}
+
+// IsIdent reports whether s is a valid identifier, optionally qualified with a module name.
+func IsIdent(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return false
+		}
+		for _, c := range part {
+			if !isIdentRune(c) {
+				return false
+			}
+		}
+	}
+	return true
+}
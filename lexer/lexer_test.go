@@ -0,0 +1,128 @@
+package lexer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// describe names each token's kind, with its text if it has any, e.g. Let Ident(x) Eq String(y) SemiColon.
+func describe(tokens []LexToken) string {
+	names := make([]string, len(tokens))
+	for i, tok := range tokens {
+		name := strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", tok), "*lexer."), "LexToken")
+		switch tok := tok.(type) {
+		case *IdentLexToken:
+			name += "(" + tok.Name + ")"
+		case *StringLexToken:
+			name += "(" + tok.Value + ")"
+		case *SourceCommentLexToken:
+			name += "(" + tok.Text + ")"
+		}
+		names[i] = name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestLex(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "statement words are identifiers",
+			src:  `let read = "x"; run x = append y;`,
+			want: "Let Ident(read) Eq String(x) SemiColon Run Ident(x) Eq Ident(append) Ident(y) SemiColon",
+		},
+		{
+			name: "keywords end at any non-word character",
+			src:  "print\tx;return\n;if\"c\"{}",
+			want: "Print Ident(x) SemiColon Return SemiColon If String(c) OpenBrace CloseBrace",
+		},
+		{
+			name: "keywords must be whole words",
+			src:  "printx; lets; run_it;",
+			want: "Ident(printx) SemiColon Ident(lets) SemiColon Ident(run_it) SemiColon",
+		},
+		{
+			name: "unicode identifiers",
+			src:  "fn größe 値 { return 値; }",
+			want: "Fn Ident(größe) Ident(値) OpenBrace Return Ident(値) SemiColon CloseBrace",
+		},
+		{
+			name: "qualified identifiers",
+			src:  "run x = s.summarise a.b.c;",
+			want: "Run Ident(x) Eq Ident(s.summarise) Ident(a.b.c) SemiColon",
+		},
+		{
+			name: "comments",
+			src:  "# one  \nlet x = /* two\nlines */ \"y\"; // three\n",
+			want: "SourceComment(# one) Let Ident(x) Eq SourceComment(/* two\nlines */) String(y) SemiColon SourceComment(// three)",
+		},
+		{
+			name: "comment characters in strings",
+			src:  `print "# not // a /* comment";`,
+			want: "Print String(# not // a /* comment) SemiColon",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tokens, err := Lex(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describe(tokens); got != c.want {
+				t.Errorf("expected\n%s\ngot\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	cases := []struct {
+		src string
+		err string
+	}{
+		{src: `let x = "no end;`, err: "1:9: unterminated string"},
+		{src: "let x = \"y\";\n/* no end", err: "2:1: unterminated block comment"},
+		{src: "print a. b;", err: "1:8: unrecognized token at start of '. b;'"},
+		{src: "let x = 5 + 4;", err: "1:11: unrecognized token at start of '+ 4;'"},
+	}
+	for _, c := range cases {
+		_, err := Lex(c.src)
+		if err == nil || !strings.Contains(strings.ReplaceAll(err.Error(), "\n", ": "), c.err) {
+			t.Errorf("lexing %q: expected an error containing %q, got %v", c.src, c.err, err)
+		}
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	tokens, err := Lex("let größe = \"ü\";\n  print größe;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1:1", "1:5", "1:11", "1:13", "1:16", "2:3", "2:9", "2:14"}
+	for i, tok := range tokens {
+		if got := tok.Position().String(); got != want[i] {
+			t.Errorf("token %d: expected position %s, got %s", i, want[i], got)
+		}
+	}
+}
+
+func TestLexTrailingComments(t *testing.T) {
+	tokens, err := Lex("# own line\nlet x = \"a\"; # trailing\n/* own */ /* trailing */ let y = \"multi\nline\"; // trailing\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trailing []bool
+	for _, tok := range tokens {
+		if comment, ok := tok.(*SourceCommentLexToken); ok {
+			trailing = append(trailing, comment.Trailing)
+		}
+	}
+	if want := []bool{false, true, false, true, true}; !slices.Equal(trailing, want) {
+		t.Errorf("expected comments to be trailing %v, got %v", want, trailing)
+	}
+}
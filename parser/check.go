@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/JoshPattman/hellm/lexer"
+)
+
+// checkScope tracks which variables are defined at each point of a program, mirroring how Scope is used at runtime.
+type checkScope struct {
+	levels []map[string]bool
+}
+
+func newCheckScope() *checkScope {
+	return &checkScope{levels: []map[string]bool{{}}}
+}
+
+func (s *checkScope) sub() *checkScope {
+	levels := make([]map[string]bool, len(s.levels)+1)
+	copy(levels, s.levels)
+	levels[len(levels)-1] = map[string]bool{}
+	return &checkScope{levels: levels}
+}
+
+func (s *checkScope) has(key string) bool {
+	// Module members can't be checked without loading the module, so any member of an imported module is allowed
+	alias, _, isMember := strings.Cut(key, ".")
+	for _, level := range s.levels {
+		if level[key] || (isMember && level[alias+".*"]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *checkScope) set(key string) {
+	if !s.has(key) {
+		s.levels[len(s.levels)-1][key] = true
+	}
+}
+
+// del only forgets variables defined in the innermost level, as a del in a nested block may not run,
+// and the check should never report a variable that might be defined.
+func (s *checkScope) del(key string) {
+	delete(s.levels[len(s.levels)-1], key)
+}
+
+// Check statically checks a parsed program, returning an error for every reference to a variable that cannot be in scope.
+// The variables named in defined are taken to be in scope already, e.g. because an earlier program defined them.
+func Check(code []ASTNode, defined ...string) []error {
+	scope := newCheckScope()
+	for _, name := range defined {
+		scope.set(name)
+	}
+	return checkNodes(code, scope)
+}
+
+func checkNodes(code []ASTNode, scope *checkScope) []error {
+	errs := []error{}
+	for _, node := range code {
+		errs = append(errs, checkNode(node, scope)...)
+	}
+	return errs
+}
+
+func checkNode(node ASTNode, scope *checkScope) []error {
+	switch n := node.(type) {
+	case LetNode:
+		errs := checkString(n.Value, n.Position(), "let "+n.Ident, scope)
+		errs = append(errs, checkIdents(n.Using, n.Position(), "let "+n.Ident, scope)...)
+		scope.set(n.Ident)
+		return errs
+	case ConstNode:
+		errs := checkString(n.Value, n.Position(), "const "+n.Ident, scope)
+		scope.set(n.Ident)
+		return errs
+	case UseNode:
+		scope.set(n.Ident)
+		return nil
+	case ImportNode:
+		scope.set(n.Alias + ".*")
+		return nil
+	case IfNode:
+		errs := checkString(n.Condition, n.Position(), "if condition", scope)
+		errs = append(errs, checkIdents(n.Using, n.Position(), "if condition", scope)...)
+		errs = append(errs, checkNodes(n.IfStatements, scope.sub())...)
+		errs = append(errs, checkNodes(n.ElseStatements, scope.sub())...)
+		return errs
+	case WhileNode:
+		errs := checkString(n.Condition, n.Position(), "while condition", scope)
+		errs = append(errs, checkIdents(n.Using, n.Position(), "while condition", scope)...)
+		errs = append(errs, checkNodes(n.Statements, scope.sub())...)
+		return errs
+	case TestNode:
+		return checkNodes(n.Statements, scope.sub())
+	case TryNode:
+		errs := checkNodes(n.Statements, scope.sub())
+		if n.CatchIdent != "" {
+			catchScope := scope.sub()
+			catchScope.set(n.CatchIdent)
+			catchScope.set(n.CatchIdent + ".kind")
+			errs = append(errs, checkNodes(n.CatchStatements, catchScope)...)
+		}
+		return append(errs, checkNodes(n.FinallyStatements, scope.sub())...)
+	case ThrowNode:
+		return checkOperands([]Operand{n.Value}, n.Position(), "throw", scope)
+	case AssertNode:
+		errs := checkString(n.Condition, n.Position(), "assert", scope)
+		return append(errs, checkIdents(n.Using, n.Position(), "assert", scope)...)
+	case AssertEqNode:
+		return checkOperands([]Operand{n.Left, n.Right}, n.Position(), "assert_eq", scope)
+	case AssertMatchesNode:
+		errs := checkOperands([]Operand{n.Value}, n.Position(), "assert_matches", scope)
+		if _, err := regexp.Compile(n.Pattern); err != nil {
+			errs = append(errs, &ParseError{Pos: n.Position(), Msg: fmt.Sprintf("assert_matches: invalid pattern: %v", err)})
+		}
+		return errs
+	case PrintNode:
+		return checkOperands(n.Values, n.Position(), "print", scope)
+	case InputNode:
+		errs := checkString(n.Prompt, n.Position(), "input "+n.Ident, scope)
+		scope.set(n.Ident)
+		return errs
+	case ReadNode:
+		errs := checkOperands([]Operand{n.Path}, n.Position(), "read "+n.Ident, scope)
+		scope.set(n.Ident)
+		return errs
+	case WriteNode:
+		return checkOperands([]Operand{n.Value, n.Path}, n.Position(), "write", scope)
+	case DelNode:
+		errs := checkIdents([]string{n.Ident}, n.Position(), "del", scope)
+		scope.del(n.Ident)
+		return errs
+	case FuncDefNode:
+		// Functions are run in a fresh scope that only contains their arguments
+		fnScope := newCheckScope()
+		for _, arg := range n.Args {
+			fnScope.set(arg)
+		}
+		return checkNodes(n.Code, fnScope)
+	case RunNode:
+		errs := checkOperands(n.Inputs, n.Position(), "run "+n.FnIdent, scope)
+		for _, ident := range n.OutputIdents {
+			scope.set(ident)
+		}
+		return errs
+	case ReturnNode:
+		return checkIdents(n.Idents, n.Position(), "return", scope)
+	default:
+		return nil
+	}
+}
+
+func checkOperands(ops []Operand, pos lexer.Pos, where string, scope *checkScope) []error {
+	errs := []error{}
+	for _, op := range ops {
+		if op.IsLiteral {
+			errs = append(errs, checkString(op.Literal, pos, where, scope)...)
+		} else {
+			errs = append(errs, checkIdents([]string{op.Ident}, pos, where, scope)...)
+		}
+	}
+	return errs
+}
+
+func checkString(s string, pos lexer.Pos, where string, scope *checkScope) []error {
+	return checkIdents(ReferencedVariables(s), pos, where, scope)
+}
+
+func checkIdents(idents []string, pos lexer.Pos, where string, scope *checkScope) []error {
+	errs := []error{}
+	for _, ident := range idents {
+		if !scope.has(ident) {
+			errs = append(errs, &ParseError{Pos: pos, Msg: fmt.Sprintf("%s: variable %s is not in scope", where, ident)})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// TestFormat formats each program in testdata/format and compares it with the .golden file next to it, then checks that formatting it again changes nothing.
+func TestFormat(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "format", "*.hl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := parse(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted := FormatProgram(code)
+			golden := strings.TrimSuffix(path, ".hl") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(formatted), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formatted != string(want) {
+				t.Errorf("formatted program does not match %s (run go test -update if the change is intended)\ngot:\n%s", golden, formatted)
+			}
+			reparsed, err := parse(formatted)
+			if err != nil {
+				t.Fatalf("formatted program does not parse: %v", err)
+			}
+			if again := FormatProgram(reparsed); again != formatted {
+				t.Errorf("formatting is not idempotent\nfirst:\n%s\nsecond:\n%s", formatted, again)
+			}
+		})
+	}
+}
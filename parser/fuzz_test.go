@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/JoshPattman/hellm/lexer"
+)
+
+// seeds are snippets of syntax that the examples don't use.
+var seeds = []string{
+	`print "Hello {name}!" greeting; printf "%s has %s apples" name count; eprint "oops"; eprintf "%s" x;`,
+	`use extra = 1 default "none"; use topic = flag "topic" default "dogs"; use key = env "API_TOKEN";`,
+	`read doc from "notes/today.md"; write doc to "out.md"; append doc to "log.md"; input name "Your name?";`,
+	`if "<city> is in europe" using city { let msg = "yes"; } else { del msg; }`,
+	`try { throw "nothing"; } catch err { eprintf "gave up ({err.kind}): {err}"; } finally { print "done"; }`,
+	`assert "it mentions the price" using summary; assert_eq status "ok"; assert_matches summary "^[A-Z].*\.$";`,
+	`import "std/text"; import "utils/strings.hl" as s; run out = s.summarize text; run a b = f x "y";`,
+	`test "summary" { run summary = s.summarise "The widget costs $5."; return summary; }`,
+	"# a comment\nfn größe wert { // another\n\tlet x = /* inline */ \"{wert}\";\n\treturn\tx;\n} /* before else */",
+	"let read = \"x\"; run y = append read; write read to \"f\"; input try; fn throw catch { return catch; }",
+	"if \"x\" { print \"a\"; } # between\nelse { return; }\ntry {} // here\ncatch e {} finally {}",
+	"let x = \"a\" # trailing\n using y; /* one */ /* two */ // three\nfn f { # after brace\n} # after fn\nwhile \"z\" /* in */ { del x; }",
+}
+
+// addExamples seeds the corpus of f with the example programs, and the seeds.
+func addExamples(f *testing.F) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	paths, err := filepath.Glob(filepath.Join("..", "examples", "*.hl"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	addExamples(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		code, err := ParseSource(src)
+		if err != nil {
+			return
+		}
+		Check(code)
+	})
+}
+
+// FuzzFormat checks that formatting a program keeps its meaning, and that formatting it again changes nothing.
+func FuzzFormat(f *testing.F) {
+	addExamples(f)
+	f.Fuzz(func(t *testing.T, src string) {
+		code, err := parse(src)
+		if err != nil {
+			return
+		}
+		formatted := FormatProgram(code)
+		reparsed, err := parse(formatted)
+		if err != nil {
+			t.Fatalf("formatted program does not parse: %v\nformatted:\n%s", err, formatted)
+		}
+		if !reflect.DeepEqual(canonical(code), canonical(reparsed)) {
+			t.Fatalf("formatting changed the program\nbefore: %#v\nafter:  %#v\nformatted:\n%s", code, reparsed, formatted)
+		}
+		if again := FormatProgram(reparsed); again != formatted {
+			t.Fatalf("formatting is not idempotent\nfirst:\n%s\nsecond:\n%s", formatted, again)
+		}
+	})
+}
+
+// parse is ParseSource without the checks, as formatting doesn't need a program to be valid.
+func parse(src string) ([]ASTNode, error) {
+	tokens, err := lexer.Lex(src)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(tokens)
+}
+
+var posType = reflect.TypeOf(lexer.Pos{})
+
+// canonical copies code with every position zeroed and every empty list nil,
+// so that programs can be compared regardless of layout, and of whether an empty block was written out.
+func canonical(code []ASTNode) []ASTNode {
+	return canonicalValue(reflect.ValueOf(code)).Interface().([]ASTNode)
+}
+
+func canonicalValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == posType {
+			return reflect.Zero(posType)
+		}
+		out := reflect.New(v.Type()).Elem()
+		for i := range v.NumField() {
+			out.Field(i).Set(canonicalValue(v.Field(i)))
+		}
+		return out
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return reflect.Zero(v.Type())
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			out.Index(i).Set(canonicalValue(v.Index(i)))
+		}
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(canonicalValue(v.Elem()))
+		return out
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(canonicalValue(v.Elem()))
+		return out
+	}
+	return v
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/JoshPattman/hellm/lexer"
+)
+
+// InterpolationPart is either literal text, or a reference to a variable if Ident is set.
+type InterpolationPart struct {
+	Text  string
+	Ident string
+}
+
+// ParseInterpolation splits s into literal text and {name} or <name> variable references.
+// A backslash before any of {}<>\ makes it literal. Brackets that do not surround a valid identifier are left as they are.
+func ParseInterpolation(s string) []InterpolationPart {
+	parts := []InterpolationPart{}
+	lit := strings.Builder{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) && strings.IndexByte(`{}<>\`, s[i+1]) >= 0 {
+			lit.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		if c == '{' || c == '<' {
+			closer := byte('}')
+			if c == '<' {
+				closer = '>'
+			}
+			if end := strings.IndexByte(s[i+1:], closer); end > 0 && lexer.IsIdent(s[i+1:i+1+end]) {
+				if lit.Len() > 0 {
+					parts = append(parts, InterpolationPart{Text: lit.String()})
+					lit.Reset()
+				}
+				parts = append(parts, InterpolationPart{Ident: s[i+1 : i+1+end]})
+				i += end + 2
+				continue
+			}
+		}
+		lit.WriteByte(c)
+		i++
+	}
+	if lit.Len() > 0 {
+		parts = append(parts, InterpolationPart{Text: lit.String()})
+	}
+	return parts
+}
+
+// ReferencedVariables returns the names of the variables referenced as {name} or <name> in text.
+func ReferencedVariables(text string) []string {
+	idents := []string{}
+	for _, part := range ParseInterpolation(text) {
+		if part.Ident != "" {
+			idents = append(idents, part.Ident)
+		}
+	}
+	return idents
+}
@@ -0,0 +1,1198 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/JoshPattman/hellm/lexer"
+)
+
+type ASTNode interface {
+	Format(indent string) string
+	// Position is where the statement starts in the source.
+	Position() lexer.Pos
+}
+
+type LetNode struct {
+	lexer.Pos
+	Ident  string
+	Value  string
+	Hidden bool
+	// Using restricts which variables are sent in the prompt. It is nil if there is no using clause.
+	Using []string
+}
+
+type ConstNode struct {
+	lexer.Pos
+	Ident  string
+	Value  string
+	Hidden bool
+}
+
+// UseSource is where a use statement reads its value from.
+type UseSource int
+
+const (
+	// UseArg reads a positional command line argument.
+	UseArg UseSource = iota
+	// UseFlag reads a named command line option, given as --name value or --name=value.
+	UseFlag
+	// UseEnv reads an environment variable.
+	UseEnv
+)
+
+type UseNode struct {
+	lexer.Pos
+	Ident  string
+	Source UseSource
+	// ArgID is the index of the positional argument, for UseArg.
+	ArgID int
+	// Name is the name of the flag or environment variable, for UseFlag and UseEnv.
+	Name string
+	// Default is used when the value is not given. It is nil if the value is required.
+	Default *string
+}
+
+type IfNode struct {
+	lexer.Pos
+	Condition      string
+	Using          []string
+	IfStatements   []ASTNode
+	ElseStatements []ASTNode
+}
+
+type WhileNode struct {
+	lexer.Pos
+	Condition  string
+	Using      []string
+	Statements []ASTNode
+}
+
+// TryNode runs Statements, running CatchStatements if they fail and FinallyStatements whatever happens.
+// At least one of the catch and finally blocks is present.
+type TryNode struct {
+	lexer.Pos
+	Statements []ASTNode
+	// CatchIdent is the variable the error message is stored in, with its kind in CatchIdent.kind. It is empty if there is no catch block.
+	CatchIdent        string
+	CatchStatements   []ASTNode
+	HasFinally        bool
+	FinallyStatements []ASTNode
+}
+
+// ThrowNode fails with its value as the error message.
+type ThrowNode struct {
+	lexer.Pos
+	Value Operand
+}
+
+// AssertNode asks the model whether Condition holds, failing if it does not.
+type AssertNode struct {
+	lexer.Pos
+	Condition string
+	Using     []string
+}
+
+// AssertEqNode fails if its two values are not equal.
+type AssertEqNode struct {
+	lexer.Pos
+	Left  Operand
+	Right Operand
+}
+
+// AssertMatchesNode fails if Value does not match the regular expression Pattern.
+// Pattern is not interpolated, so that it can use braces.
+type AssertMatchesNode struct {
+	lexer.Pos
+	Value   Operand
+	Pattern string
+}
+
+// TestNode is a test that hellm test runs in a scope of its own, after the rest of the file.
+// Test blocks are skipped when the file is run normally.
+type TestNode struct {
+	lexer.Pos
+	Name       string
+	Statements []ASTNode
+}
+
+// Operand is a value used by a statement: either a variable, or a string literal that may interpolate variables.
+type Operand struct {
+	Ident     string
+	Literal   string
+	IsLiteral bool
+}
+
+type PrintNode struct {
+	lexer.Pos
+	Values []Operand
+	// Formatted nodes use their first value as a printf format for the rest.
+	Formatted bool
+	// Stderr nodes print to stderr instead of stdout.
+	Stderr bool
+}
+
+type InputNode struct {
+	lexer.Pos
+	Ident string
+	// Prompt is written to stdout before reading a line. It may be empty.
+	Prompt string
+	// All reads the whole of stdin rather than a single line.
+	All bool
+}
+
+type ReadNode struct {
+	lexer.Pos
+	Ident string
+	Path  Operand
+}
+
+type WriteNode struct {
+	lexer.Pos
+	Value Operand
+	Path  Operand
+	// Append adds to the end of the file instead of replacing it.
+	Append bool
+}
+
+type ImportNode struct {
+	lexer.Pos
+	Path string
+	// Alias is the name the module's functions and consts are accessed through, e.g. alias.fn.
+	Alias string
+}
+
+type CommentNode struct {
+	lexer.Pos
+	Comment string
+}
+
+// SourceCommentNode is a #, // or /* */ comment. It does nothing, and unlike a com statement it is not traced or stepped through.
+type SourceCommentNode struct {
+	lexer.Pos
+	Text string
+	// Trailing is set if the comment follows the statement before it on the same line, and is formatted there.
+	Trailing bool
+}
+
+type DelNode struct {
+	lexer.Pos
+	Ident string
+}
+
+type RunNode struct {
+	lexer.Pos
+	OutputIdents []string
+	FnIdent      string
+	Inputs       []Operand
+}
+
+type ReturnNode struct {
+	lexer.Pos
+	Idents []string
+}
+
+type FuncDefNode struct {
+	lexer.Pos
+	Ident string
+	Args  []string
+	Code  []ASTNode
+}
+
+func (n LetNode) Format(indent string) string {
+	return fmt.Sprintf("%slet %s%s = \"%s\"%s;", indent, formatHidden(n.Hidden), n.Ident, n.Value, formatUsing(n.Using))
+}
+func (n ConstNode) Format(indent string) string {
+	return fmt.Sprintf("%sconst %s%s = \"%s\";", indent, formatHidden(n.Hidden), n.Ident, n.Value)
+}
+func (n UseNode) Format(indent string) string {
+	source := ""
+	switch n.Source {
+	case UseArg:
+		source = strconv.Itoa(n.ArgID)
+	case UseFlag:
+		source = fmt.Sprintf("flag \"%s\"", n.Name)
+	case UseEnv:
+		source = fmt.Sprintf("env \"%s\"", n.Name)
+	}
+	if n.Default != nil {
+		source += fmt.Sprintf(" default \"%s\"", *n.Default)
+	}
+	return fmt.Sprintf("%suse %s = %s;", indent, n.Ident, source)
+}
+func (n IfNode) Format(indent string) string {
+	if len(n.ElseStatements) == 0 {
+		return fmt.Sprintf("%sif \"%s\"%s %s", indent, n.Condition, formatUsing(n.Using), formatBlock(n.IfStatements, indent))
+	} else {
+		return fmt.Sprintf("%sif \"%s\"%s %s else %s", indent, n.Condition, formatUsing(n.Using), formatBlock(n.IfStatements, indent), formatBlock(n.ElseStatements, indent))
+	}
+}
+func (n WhileNode) Format(indent string) string {
+	return fmt.Sprintf("%swhile \"%s\"%s %s", indent, n.Condition, formatUsing(n.Using), formatBlock(n.Statements, indent))
+}
+func (n TryNode) Format(indent string) string {
+	result := fmt.Sprintf("%stry %s", indent, formatBlock(n.Statements, indent))
+	if n.CatchIdent != "" {
+		result += fmt.Sprintf(" catch %s %s", n.CatchIdent, formatBlock(n.CatchStatements, indent))
+	}
+	if n.HasFinally {
+		result += fmt.Sprintf(" finally %s", formatBlock(n.FinallyStatements, indent))
+	}
+	return result
+}
+func (n ThrowNode) Format(indent string) string {
+	return fmt.Sprintf("%sthrow %s;", indent, n.Value.Format())
+}
+func (n AssertNode) Format(indent string) string {
+	return fmt.Sprintf("%sassert \"%s\"%s;", indent, n.Condition, formatUsing(n.Using))
+}
+func (n AssertEqNode) Format(indent string) string {
+	return fmt.Sprintf("%sassert_eq %s %s;", indent, n.Left.Format(), n.Right.Format())
+}
+func (n AssertMatchesNode) Format(indent string) string {
+	return fmt.Sprintf("%sassert_matches %s \"%s\";", indent, n.Value.Format(), n.Pattern)
+}
+func (n TestNode) Format(indent string) string {
+	return fmt.Sprintf("%stest \"%s\" %s", indent, n.Name, formatBlock(n.Statements, indent))
+}
+func (n PrintNode) Format(indent string) string {
+	keyword := "print"
+	if n.Stderr {
+		keyword = "e" + keyword
+	}
+	if n.Formatted {
+		keyword += "f"
+	}
+	return fmt.Sprintf("%s%s %s;", indent, keyword, formatOperands(n.Values))
+}
+func (n InputNode) Format(indent string) string {
+	if n.All {
+		return fmt.Sprintf("%sinput %s from stdin;", indent, n.Ident)
+	}
+	if n.Prompt != "" {
+		return fmt.Sprintf("%sinput %s \"%s\";", indent, n.Ident, n.Prompt)
+	}
+	return fmt.Sprintf("%sinput %s;", indent, n.Ident)
+}
+func (n ReadNode) Format(indent string) string {
+	return fmt.Sprintf("%sread %s from %s;", indent, n.Ident, n.Path.Format())
+}
+func (n WriteNode) Format(indent string) string {
+	keyword := "write"
+	if n.Append {
+		keyword = "append"
+	}
+	return fmt.Sprintf("%s%s %s to %s;", indent, keyword, n.Value.Format(), n.Path.Format())
+}
+func (n ImportNode) Format(indent string) string {
+	if n.Alias == defaultImportAlias(n.Path) {
+		return fmt.Sprintf("%simport \"%s\";", indent, n.Path)
+	}
+	return fmt.Sprintf("%simport \"%s\" as %s;", indent, n.Path, n.Alias)
+}
+func (n CommentNode) Format(indent string) string {
+	return fmt.Sprintf("\n%scom \"%s\";", indent, n.Comment)
+}
+func (n SourceCommentNode) Format(indent string) string {
+	return indent + n.Text
+}
+func (n DelNode) Format(indent string) string {
+	return fmt.Sprintf("%sdel %s;", indent, n.Ident)
+}
+func (n RunNode) Format(indent string) string {
+	outputs := ""
+	if len(n.OutputIdents) > 0 {
+		outputs = strings.Join(n.OutputIdents, " ")
+	}
+	inputs := formatOperands(n.Inputs)
+	if outputs != "" && inputs != "" {
+		return fmt.Sprintf("%srun %s = %s %s;", indent, outputs, n.FnIdent, inputs)
+	} else if outputs != "" {
+		return fmt.Sprintf("%srun %s = %s;", indent, outputs, n.FnIdent)
+	} else if inputs != "" {
+		return fmt.Sprintf("%srun %s %s;", indent, n.FnIdent, inputs)
+	} else {
+		return fmt.Sprintf("%srun %s;", indent, n.FnIdent)
+	}
+}
+func (n ReturnNode) Format(indent string) string {
+	if len(n.Idents) == 0 {
+		return indent + "return;"
+	}
+	idents := strings.Join(n.Idents, " ")
+	return fmt.Sprintf("%sreturn %s;", indent, idents)
+}
+func (n FuncDefNode) Format(indent string) string {
+	args := ""
+	if len(n.Args) > 0 {
+		args = " " + strings.Join(n.Args, " ")
+	}
+	return fmt.Sprintf("\n%sfn %s%s %s\n", indent, n.Ident, args, formatBlock(n.Code, indent))
+}
+
+func (o Operand) Format() string {
+	if o.IsLiteral {
+		return fmt.Sprintf("\"%s\"", o.Literal)
+	}
+	return o.Ident
+}
+
+func formatOperands(ops []Operand) string {
+	formatted := make([]string, len(ops))
+	for i, op := range ops {
+		formatted[i] = op.Format()
+	}
+	return strings.Join(formatted, " ")
+}
+
+func formatHidden(hidden bool) string {
+	if hidden {
+		return "hidden "
+	}
+	return ""
+}
+
+// FormatProgram formats the statements of a whole program, as hellm format writes it.
+func FormatProgram(code []ASTNode) string {
+	if len(code) == 0 {
+		return ""
+	}
+	return formatStatements(code, "") + "\n"
+}
+
+// formatStatements formats each statement on lines of its own, apart from trailing comments, which go at the end of the line before.
+func formatStatements(statements []ASTNode, indent string) string {
+	b := &strings.Builder{}
+	for i, stmt := range statements {
+		if comment, ok := stmt.(SourceCommentNode); ok && comment.Trailing {
+			// Statements such as fn end with a blank line, which the comment must go before.
+			before := b.String()
+			line := strings.TrimRight(before, "\n")
+			b.Reset()
+			b.WriteString(line + " " + comment.Text + before[len(line):])
+			continue
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(stmt.Format(indent))
+	}
+	return b.String()
+}
+
+// formatBlock formats statements in braces, indented one level more than indent.
+func formatBlock(statements []ASTNode, indent string) string {
+	body := formatStatements(statements, indent+"    ")
+	if len(statements) > 0 {
+		if comment, ok := statements[0].(SourceCommentNode); ok && comment.Trailing {
+			// The comment was on the same line as the opening brace.
+			return "{" + body + "\n" + indent + "}"
+		}
+	}
+	return "{\n" + body + "\n" + indent + "}"
+}
+
+func formatUsing(using []string) string {
+	if using == nil {
+		return ""
+	}
+	return " using " + strings.Join(using, ", ")
+}
+
+// WalkNodes calls fn for every node in code, including those nested inside blocks and function definitions.
+func WalkNodes(code []ASTNode, fn func(ASTNode)) {
+	for _, node := range code {
+		fn(node)
+		switch n := node.(type) {
+		case IfNode:
+			WalkNodes(n.IfStatements, fn)
+			WalkNodes(n.ElseStatements, fn)
+		case WhileNode:
+			WalkNodes(n.Statements, fn)
+		case TestNode:
+			WalkNodes(n.Statements, fn)
+		case TryNode:
+			WalkNodes(n.Statements, fn)
+			WalkNodes(n.CatchStatements, fn)
+			WalkNodes(n.FinallyStatements, fn)
+		case FuncDefNode:
+			WalkNodes(n.Code, fn)
+		}
+	}
+}
+
+// ParseError is a problem with the source of a program, found while lexing, parsing or checking it.
+type ParseError struct {
+	// Pos is where the problem is. It is zero if it is not known, in which case Msg says where it is if it can.
+	Pos lexer.Pos
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	if e.Pos == (lexer.Pos{}) {
+		return e.Msg
+	}
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ParseSource lexes, parses and checks the program in src. Every problem found is a *ParseError, and those found by Check are joined into one error.
+// The variables named in defined are taken to be in scope already, as with Check.
+func ParseSource(src string, defined ...string) ([]ASTNode, error) {
+	tokens, err := lexer.Lex(src)
+	if err != nil {
+		return nil, &ParseError{Msg: err.Error()}
+	}
+	code, err := Parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if errs := Check(code, defined...); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return code, nil
+}
+
+func Parse(tokens []lexer.LexToken) ([]ASTNode, error) {
+	tokens = hoistComments(tokens)
+	nodes := []ASTNode{}
+	for len(tokens) > 0 {
+		node, rest, ok := tryParseNode(tokens)
+		if !ok {
+			return nil, &ParseError{Pos: tokens[0].Position(), Msg: fmt.Sprintf("failed to parse tokens: %v", lexer.FormatLexTokens(tokens))}
+		}
+		nodes = append(nodes, node)
+		tokens = rest
+	}
+
+	if len(tokens) > 0 {
+		return nil, &ParseError{Pos: tokens[0].Position(), Msg: fmt.Sprintf("unexpected tokens remaining after parsing: %v", tokens)}
+	}
+
+	return nodes, nil
+
+}
+
+// hoistComments moves each comment that is inside a statement out of it, so that comments are only ever where a statement could be,
+// and the statement patterns never see them. Comments on a line of their own go just before the statement,
+// and trailing ones go after the end of the line the statement starts on (its ; or {), so that they stay on that line when formatted.
+func hoistComments(tokens []lexer.LexToken) []lexer.LexToken {
+	hoisted := make([]lexer.LexToken, 0, len(tokens))
+	// start is where the statement being read starts in hoisted, and outer holds the starts of the statements around it.
+	start := 0
+	outer := []int{}
+	// trailing holds the trailing comments inside the statement being read.
+	var trailing []lexer.LexToken
+	flush := func() {
+		for i, tok := range trailing {
+			comment := *tok.(*lexer.SourceCommentLexToken)
+			// Only the first can share the line, as the others came after it or a line comment.
+			comment.Trailing = i == 0
+			hoisted = append(hoisted, &comment)
+		}
+		trailing = nil
+		start = len(hoisted)
+	}
+	for i, tok := range tokens {
+		switch tok := tok.(type) {
+		case *lexer.SourceCommentLexToken:
+			if start < len(hoisted) && tok.Trailing {
+				trailing = append(trailing, tok)
+				continue
+			}
+			if tok.Trailing && (start == 0 || isLineComment(hoisted[start-1])) {
+				// It would be put after something it cannot share a line with.
+				comment := *tok
+				comment.Trailing = false
+				tok = &comment
+			}
+			hoisted = slices.Insert(hoisted, start, lexer.LexToken(tok))
+			start++
+			continue
+		case *lexer.SemiColonLexToken:
+			hoisted = append(hoisted, tok)
+			flush()
+			continue
+		case *lexer.OpenBraceLexToken:
+			hoisted = append(hoisted, tok)
+			outer = append(outer, start)
+			flush()
+			continue
+		case *lexer.CloseBraceLexToken:
+			hoisted = append(hoisted, tok)
+			flush()
+			if len(outer) > 0 {
+				start, outer = outer[len(outer)-1], outer[:len(outer)-1]
+			}
+			if !continuesStatement(tokens[i+1:]) {
+				start = len(hoisted)
+			}
+			continue
+		}
+		hoisted = append(hoisted, tok)
+	}
+	flush()
+	return hoisted
+}
+
+// isLineComment reports whether tok is a # or // comment, which runs to the end of its line.
+func isLineComment(tok lexer.LexToken) bool {
+	comment, ok := tok.(*lexer.SourceCommentLexToken)
+	return ok && !strings.HasPrefix(comment.Text, "/*")
+}
+
+// continuesStatement reports whether tokens, which follow a closing brace, carry on the statement that the brace is part of.
+func continuesStatement(tokens []lexer.LexToken) bool {
+	for _, tok := range tokens {
+		switch tok := tok.(type) {
+		case *lexer.SourceCommentLexToken:
+			continue
+		case *lexer.ElseLexToken:
+			return true
+		case *lexer.IdentLexToken:
+			return tok.Name == "catch" || tok.Name == "finally"
+		}
+		return false
+	}
+	return false
+}
+
+type patternMatchList[T lexer.LexToken] struct {
+	elems []T
+}
+
+// Copies tokens from the tokens array into our array until a token that is the wrong type is reached.
+// Returns the amount it copied.
+func (p *patternMatchList[T]) Copy(tokens []lexer.LexToken) (int, bool) {
+	for i, tok := range tokens {
+		if tok, ok := tok.(T); !ok {
+			return i, true
+		} else {
+			p.elems = append(p.elems, tok)
+		}
+	}
+	return len(tokens), true
+}
+
+// patternMatchOperands matches one or more identifiers or strings, or zero or more if optional is set.
+type patternMatchOperands struct {
+	ops      []Operand
+	optional bool
+}
+
+func (p *patternMatchOperands) Copy(tokens []lexer.LexToken) (int, bool) {
+	for i, tok := range tokens {
+		switch tok := tok.(type) {
+		case *lexer.IdentLexToken:
+			p.ops = append(p.ops, Operand{Ident: tok.Name})
+		case *lexer.StringLexToken:
+			p.ops = append(p.ops, Operand{Literal: tok.Value, IsLiteral: true})
+		default:
+			return i, i > 0 || p.optional
+		}
+	}
+	return len(tokens), len(tokens) > 0 || p.optional
+}
+
+// patternMatchOperand matches a single identifier or string.
+type patternMatchOperand struct {
+	op Operand
+}
+
+func (p *patternMatchOperand) Copy(tokens []lexer.LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	switch tok := tokens[0].(type) {
+	case *lexer.IdentLexToken:
+		p.op = Operand{Ident: tok.Name}
+	case *lexer.StringLexToken:
+		p.op = Operand{Literal: tok.Value, IsLiteral: true}
+	default:
+		return 0, false
+	}
+	return 1, true
+}
+
+// patternMatchWord matches an identifier with a specific name, for words that are only keywords in certain positions.
+type patternMatchWord struct {
+	lexer.Pos
+	name string
+}
+
+func (p *patternMatchWord) Copy(tokens []lexer.LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, false
+	}
+	if tok, ok := tokens[0].(*lexer.IdentLexToken); !ok || tok.Name != p.name {
+		return 0, false
+	}
+	p.Pos = tokens[0].Position()
+	return 1, true
+}
+
+// patternMatchModifier optionally matches an identifier with a specific name that is followed by another identifier.
+// This lets words like hidden act as keywords in front of a variable name while still being usable as names themselves.
+type patternMatchModifier struct {
+	name    string
+	present bool
+}
+
+func (p *patternMatchModifier) Copy(tokens []lexer.LexToken) (int, bool) {
+	if len(tokens) < 2 {
+		return 0, true
+	}
+	if tok, ok := tokens[0].(*lexer.IdentLexToken); !ok || tok.Name != p.name {
+		return 0, true
+	}
+	if _, ok := tokens[1].(*lexer.IdentLexToken); !ok {
+		return 0, true
+	}
+	p.present = true
+	return 1, true
+}
+
+// patternMatchUsing optionally matches a using clause: the identifier using followed by a comma separated list of identifiers.
+type patternMatchUsing struct {
+	idents []string
+}
+
+func (p *patternMatchUsing) Copy(tokens []lexer.LexToken) (int, bool) {
+	if len(tokens) < 1 {
+		return 0, true
+	}
+	if tok, ok := tokens[0].(*lexer.IdentLexToken); !ok || tok.Name != "using" {
+		return 0, true
+	}
+	idents := []string{}
+	i := 1
+	for {
+		if i >= len(tokens) {
+			return 0, false
+		}
+		ident, ok := tokens[i].(*lexer.IdentLexToken)
+		if !ok {
+			return 0, false
+		}
+		idents = append(idents, ident.Name)
+		i++
+		if i < len(tokens) {
+			if _, ok := tokens[i].(*lexer.CommaLexToken); ok {
+				i++
+				continue
+			}
+		}
+		break
+	}
+	p.idents = idents
+	return i, true
+}
+
+func patternMatch(tokens []lexer.LexToken, pattern ...lexer.PatternMatchable) (bool, []lexer.LexToken) {
+	ti := 0
+	for _, pat := range pattern {
+		if ti > len(tokens) {
+			return false, nil
+		}
+		inc, ok := pat.Copy(tokens[ti:])
+		if !ok {
+			return false, nil
+		}
+		ti += inc
+	}
+	return true, tokens[ti:]
+}
+
+func parseNodesUntilNoMoreParse(tokens []lexer.LexToken) ([]ASTNode, []lexer.LexToken) {
+	nodes := []ASTNode{}
+	for len(tokens) > 0 {
+		node, rest, ok := tryParseNode(tokens)
+		if !ok {
+			break
+		}
+		nodes = append(nodes, node)
+		tokens = rest
+	}
+	return nodes, tokens
+}
+
+func tryParseNode(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	parseFuncs := []func([]lexer.LexToken) (ASTNode, []lexer.LexToken, bool){
+		tryParseLet,
+		tryParseConst,
+		tryParseUse,
+		tryParseIf,
+		tryParseFuncDef,
+		tryParseWhile,
+		tryParseTry,
+		tryParseThrow,
+		tryParseAssert,
+		tryParseTest,
+		tryParseAssertEq,
+		tryParseAssertMatches,
+		tryParsePrint,
+		tryParseInput,
+		tryParseRead,
+		tryParseImport,
+		tryParseWrite,
+		tryParseDel,
+		tryParseComment,
+		tryParseSourceComment,
+		tryParseReturn,
+		tryParseRun,
+		tryParseNoAssnRun,
+	}
+	for _, parseFunc := range parseFuncs {
+		node, rest, ok := parseFunc(tokens)
+		if ok {
+			return node, rest, true
+		}
+	}
+	return nil, tokens, false
+}
+
+func tryParseDel(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	ident := &lexer.IdentLexToken{}
+	if ok, rest := patternMatch(tokens, &lexer.DelLexToken{}, ident, &lexer.SemiColonLexToken{}); ok {
+		return DelNode{
+			Pos:   tokens[0].Position(),
+			Ident: ident.Name,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseLet(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	hidden := &patternMatchModifier{name: "hidden"}
+	ident := &lexer.IdentLexToken{}
+	value := &lexer.StringLexToken{}
+	using := &patternMatchUsing{}
+	if ok, rest := patternMatch(tokens, &lexer.LetLexToken{}, hidden, ident, &lexer.EqLexToken{}, value, using, &lexer.SemiColonLexToken{}); ok {
+		return LetNode{
+			Pos:    tokens[0].Position(),
+			Ident:  ident.Name,
+			Value:  value.Value,
+			Hidden: hidden.present,
+			Using:  using.idents,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseConst(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	hidden := &patternMatchModifier{name: "hidden"}
+	ident := &lexer.IdentLexToken{}
+	value := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &lexer.ConstLexToken{}, hidden, ident, &lexer.EqLexToken{}, value, &lexer.SemiColonLexToken{}); ok {
+		return ConstNode{
+			Pos:    tokens[0].Position(),
+			Ident:  ident.Name,
+			Value:  value.Value,
+			Hidden: hidden.present,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseUse(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	keyword := &lexer.UseLexToken{}
+	ident := &lexer.IdentLexToken{}
+	ok, tokens := patternMatch(tokens, keyword, ident, &lexer.EqLexToken{})
+	if !ok {
+		return nil, nil, false
+	}
+	node := UseNode{Pos: keyword.Position(), Ident: ident.Name}
+	argID := &lexer.IdentLexToken{}
+	name := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "flag"}, name); ok {
+		node.Source = UseFlag
+		node.Name = name.Value
+		tokens = rest
+	} else if ok, rest := patternMatch(tokens, &patternMatchWord{name: "env"}, name); ok {
+		node.Source = UseEnv
+		node.Name = name.Value
+		tokens = rest
+	} else if ok, rest := patternMatch(tokens, argID); ok {
+		id, err := strconv.Atoi(argID.Name)
+		if err != nil {
+			return nil, nil, false
+		}
+		node.Source = UseArg
+		node.ArgID = id
+		tokens = rest
+	} else {
+		return nil, nil, false
+	}
+	def := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "default"}, def); ok {
+		node.Default = &def.Value
+		tokens = rest
+	}
+	if ok, rest := patternMatch(tokens, &lexer.SemiColonLexToken{}); ok {
+		return node, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseIf(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	keyword := &lexer.IfLexToken{}
+	condition := &lexer.StringLexToken{}
+	using := &patternMatchUsing{}
+	if ok, tokens := patternMatch(tokens, keyword, condition, using, &lexer.OpenBraceLexToken{}); ok {
+		var ifChildren, elseChildren []ASTNode
+		ifChildren, tokens = parseNodesUntilNoMoreParse(tokens)
+		if ok, tokens = patternMatch(tokens, &lexer.CloseBraceLexToken{}); !ok {
+			return nil, nil, false
+		}
+		if ok, elseTokens := patternMatch(tokens, &lexer.ElseLexToken{}, &lexer.OpenBraceLexToken{}); ok {
+			elseChildren, elseTokens = parseNodesUntilNoMoreParse(elseTokens)
+			if ok, elseTokens = patternMatch(elseTokens, &lexer.CloseBraceLexToken{}); !ok {
+				return nil, nil, false
+			}
+			tokens = elseTokens
+		}
+		return IfNode{
+			Pos:            keyword.Position(),
+			Condition:      condition.Value,
+			Using:          using.idents,
+			IfStatements:   ifChildren,
+			ElseStatements: elseChildren,
+		}, tokens, true
+	}
+	return nil, nil, false
+}
+
+func tryParseFuncDef(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	keyword := &lexer.FnLexToken{}
+	name := &lexer.IdentLexToken{}
+	args := &patternMatchList[*lexer.IdentLexToken]{}
+	if ok, tokens := patternMatch(tokens, keyword, name, args, &lexer.OpenBraceLexToken{}); ok {
+		var children []ASTNode
+		children, tokens = parseNodesUntilNoMoreParse(tokens)
+		if ok, tokens = patternMatch(tokens, &lexer.CloseBraceLexToken{}); !ok {
+			return nil, nil, false
+		}
+		argNames := make([]string, len(args.elems))
+		for i, arg := range args.elems {
+			argNames[i] = arg.Name
+		}
+		return FuncDefNode{
+			Pos:   keyword.Position(),
+			Ident: name.Name,
+			Args:  argNames,
+			Code:  children,
+		}, tokens, true
+	}
+	return nil, nil, false
+}
+
+func tryParseWhile(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	keyword := &lexer.WhileLexToken{}
+	condition := &lexer.StringLexToken{}
+	using := &patternMatchUsing{}
+	if ok, tokens := patternMatch(tokens, keyword, condition, using, &lexer.OpenBraceLexToken{}); ok {
+		statements, tokens := parseNodesUntilNoMoreParse(tokens)
+		if ok, tokens := patternMatch(tokens, &lexer.CloseBraceLexToken{}); ok {
+			return WhileNode{
+				Pos:        keyword.Position(),
+				Condition:  condition.Value,
+				Using:      using.idents,
+				Statements: statements,
+			}, tokens, true
+		}
+	}
+	return nil, nil, false
+}
+
+// tryParseTry parses a try statement. try, catch and finally are not keywords, so that they can still be used as names.
+func tryParseTry(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	keyword := &patternMatchWord{name: "try"}
+	ok, tokens := patternMatch(tokens, keyword, &lexer.OpenBraceLexToken{})
+	if !ok {
+		return nil, nil, false
+	}
+	node := TryNode{Pos: keyword.Position()}
+	node.Statements, tokens = parseNodesUntilNoMoreParse(tokens)
+	if ok, tokens = patternMatch(tokens, &lexer.CloseBraceLexToken{}); !ok {
+		return nil, nil, false
+	}
+	ident := &lexer.IdentLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "catch"}, ident, &lexer.OpenBraceLexToken{}); ok {
+		node.CatchIdent = ident.Name
+		node.CatchStatements, rest = parseNodesUntilNoMoreParse(rest)
+		if ok, rest = patternMatch(rest, &lexer.CloseBraceLexToken{}); !ok {
+			return nil, nil, false
+		}
+		tokens = rest
+	}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "finally"}, &lexer.OpenBraceLexToken{}); ok {
+		node.HasFinally = true
+		node.FinallyStatements, rest = parseNodesUntilNoMoreParse(rest)
+		if ok, rest = patternMatch(rest, &lexer.CloseBraceLexToken{}); !ok {
+			return nil, nil, false
+		}
+		tokens = rest
+	}
+	if node.CatchIdent == "" && !node.HasFinally {
+		return nil, nil, false
+	}
+	return node, tokens, true
+}
+
+func tryParseThrow(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	value := &patternMatchOperand{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "throw"}, value, &lexer.SemiColonLexToken{}); ok {
+		return ThrowNode{
+			Pos:   tokens[0].Position(),
+			Value: value.op,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseAssert(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	condition := &lexer.StringLexToken{}
+	using := &patternMatchUsing{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "assert"}, condition, using, &lexer.SemiColonLexToken{}); ok {
+		return AssertNode{
+			Pos:       tokens[0].Position(),
+			Condition: condition.Value,
+			Using:     using.idents,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseAssertEq(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	left := &patternMatchOperand{}
+	right := &patternMatchOperand{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "assert_eq"}, left, right, &lexer.SemiColonLexToken{}); ok {
+		return AssertEqNode{
+			Pos:   tokens[0].Position(),
+			Left:  left.op,
+			Right: right.op,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseAssertMatches(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	value := &patternMatchOperand{}
+	pattern := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "assert_matches"}, value, pattern, &lexer.SemiColonLexToken{}); ok {
+		return AssertMatchesNode{
+			Pos:     tokens[0].Position(),
+			Value:   value.op,
+			Pattern: pattern.Value,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+// tryParseTest parses a test block. test is not a keyword, so that it can still be used as a variable name.
+func tryParseTest(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	name := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "test"}, name, &lexer.OpenBraceLexToken{}); ok {
+		statements, rest := parseNodesUntilNoMoreParse(rest)
+		if ok, rest := patternMatch(rest, &lexer.CloseBraceLexToken{}); ok {
+			return TestNode{
+				Pos:        tokens[0].Position(),
+				Name:       name.Value,
+				Statements: statements,
+			}, rest, true
+		}
+	}
+	return nil, nil, false
+}
+
+func tryParsePrint(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	if len(tokens) < 1 {
+		return nil, nil, false
+	}
+	var keyword lexer.PatternMatchable
+	node := PrintNode{Pos: tokens[0].Position()}
+	switch tok := tokens[0].(type) {
+	case *lexer.PrintLexToken:
+		keyword = &lexer.PrintLexToken{}
+	case *lexer.IdentLexToken:
+		switch tok.Name {
+		case "printf":
+			node.Formatted = true
+		case "eprint":
+			node.Stderr = true
+		case "eprintf":
+			node.Formatted = true
+			node.Stderr = true
+		default:
+			return nil, nil, false
+		}
+		keyword = &patternMatchWord{name: tok.Name}
+	default:
+		return nil, nil, false
+	}
+	values := &patternMatchOperands{}
+	if ok, rest := patternMatch(tokens, keyword, values, &lexer.SemiColonLexToken{}); ok {
+		node.Values = values.ops
+		return node, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseInput(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	ident := &lexer.IdentLexToken{}
+	prompt := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "input"}, ident, &patternMatchWord{name: "from"}, &patternMatchWord{name: "stdin"}, &lexer.SemiColonLexToken{}); ok {
+		return InputNode{
+			Pos:   tokens[0].Position(),
+			Ident: ident.Name,
+			All:   true,
+		}, rest, true
+	}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "input"}, ident, prompt, &lexer.SemiColonLexToken{}); ok {
+		return InputNode{
+			Pos:    tokens[0].Position(),
+			Ident:  ident.Name,
+			Prompt: prompt.Value,
+		}, rest, true
+	}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "input"}, ident, &lexer.SemiColonLexToken{}); ok {
+		return InputNode{
+			Pos:   tokens[0].Position(),
+			Ident: ident.Name,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseRead(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	ident := &lexer.IdentLexToken{}
+	path := &patternMatchOperand{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "read"}, ident, &patternMatchWord{name: "from"}, path, &lexer.SemiColonLexToken{}); ok {
+		return ReadNode{
+			Pos:   tokens[0].Position(),
+			Ident: ident.Name,
+			Path:  path.op,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseWrite(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	if len(tokens) < 1 {
+		return nil, nil, false
+	}
+	word, ok := tokens[0].(*lexer.IdentLexToken)
+	if !ok || (word.Name != "write" && word.Name != "append") {
+		return nil, nil, false
+	}
+	value := &patternMatchOperand{}
+	path := &patternMatchOperand{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: word.Name}, value, &patternMatchWord{name: "to"}, path, &lexer.SemiColonLexToken{}); ok {
+		return WriteNode{
+			Pos:    tokens[0].Position(),
+			Value:  value.op,
+			Path:   path.op,
+			Append: word.Name == "append",
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseImport(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	path := &lexer.StringLexToken{}
+	alias := &lexer.IdentLexToken{}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "import"}, path, &patternMatchWord{name: "as"}, alias, &lexer.SemiColonLexToken{}); ok {
+		return ImportNode{
+			Pos:   tokens[0].Position(),
+			Path:  path.Value,
+			Alias: alias.Name,
+		}, rest, true
+	}
+	if ok, rest := patternMatch(tokens, &patternMatchWord{name: "import"}, path, &lexer.SemiColonLexToken{}); ok {
+		return ImportNode{
+			Pos:   tokens[0].Position(),
+			Path:  path.Value,
+			Alias: defaultImportAlias(path.Value),
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+// defaultImportAlias is the alias used for an import without an as clause: the file name without its extension.
+func defaultImportAlias(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".hl")
+}
+
+func tryParseComment(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	message := &lexer.StringLexToken{}
+	if ok, rest := patternMatch(tokens, &lexer.CommentLexToken{}, message, &lexer.SemiColonLexToken{}); ok {
+		return CommentNode{
+			Pos:     tokens[0].Position(),
+			Comment: message.Value,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseSourceComment(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	comment := &lexer.SourceCommentLexToken{}
+	if ok, rest := patternMatch(tokens, comment); ok {
+		return SourceCommentNode{
+			Pos:      comment.Position(),
+			Text:     comment.Text,
+			Trailing: comment.Trailing,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseReturn(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	idents := &patternMatchList[*lexer.IdentLexToken]{}
+	if ok, rest := patternMatch(tokens, &lexer.ReturnLexToken{}, idents, &lexer.SemiColonLexToken{}); ok {
+		identNames := make([]string, len(idents.elems))
+		for i, ident := range idents.elems {
+			identNames[i] = ident.Name
+		}
+		return ReturnNode{
+			Pos:    tokens[0].Position(),
+			Idents: identNames,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseRun(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	outputIdents := &patternMatchList[*lexer.IdentLexToken]{}
+	inputs := &patternMatchOperands{optional: true}
+	fnIdent := &lexer.IdentLexToken{}
+	if ok, rest := patternMatch(tokens, &lexer.RunLexToken{}, outputIdents, &lexer.EqLexToken{}, fnIdent, inputs, &lexer.SemiColonLexToken{}); ok {
+		outputs := make([]string, len(outputIdents.elems))
+		for i, ident := range outputIdents.elems {
+			outputs[i] = ident.Name
+		}
+		return RunNode{
+			Pos:          tokens[0].Position(),
+			OutputIdents: outputs,
+			Inputs:       inputs.ops,
+			FnIdent:      fnIdent.Name,
+		}, rest, true
+	}
+	return nil, nil, false
+}
+
+func tryParseNoAssnRun(tokens []lexer.LexToken) (ASTNode, []lexer.LexToken, bool) {
+	inputs := &patternMatchOperands{optional: true}
+	fnIdent := &lexer.IdentLexToken{}
+	if ok, rest := patternMatch(tokens, &lexer.RunLexToken{}, fnIdent, inputs, &lexer.SemiColonLexToken{}); ok {
+		return RunNode{
+			Pos:          tokens[0].Position(),
+			OutputIdents: []string{},
+			Inputs:       inputs.ops,
+			FnIdent:      fnIdent.Name,
+		}, rest, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,183 @@
+// Package hellm runs hellm programs from Go.
+//
+// The lexer, parser, interpreter and backend packages can be used on their own,
+// but a Runtime ties them together for the common case of loading a program and calling its functions.
+package hellm
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/JoshPattman/hellm/backend"
+	"github.com/JoshPattman/hellm/interpreter"
+	"github.com/JoshPattman/hellm/parser"
+	"github.com/JoshPattman/jpf"
+)
+
+// Runtime runs hellm programs. The functions and variables that loaded programs define are kept,
+// so a program can be loaded once and its functions called many times.
+type Runtime struct {
+	interp   *interpreter.Interpreter
+	builtins interpreter.Builtins
+}
+
+type RuntimeBuilder struct {
+	model  jpf.Model
+	stdin  io.Reader
+	stdout io.Writer
+	price  backend.Price
+	limits backend.Limits
+	opts   interpreter.Options
+}
+
+// BuildRuntime creates a runtime whose LLM-backed statements are answered by model.
+// Programs cannot access files unless WithFSRoot is used.
+func BuildRuntime(model jpf.Model) *RuntimeBuilder {
+	return &RuntimeBuilder{
+		model:  model,
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		opts: interpreter.Options{
+			Stderr:   os.Stderr,
+			Builtins: interpreter.DefaultBuiltins(),
+		},
+	}
+}
+
+func (b *RuntimeBuilder) Validate() (*Runtime, error) {
+	if b.model == nil {
+		return nil, fmt.Errorf("runtime requires a model")
+	}
+	opts := b.opts
+	opts.Model = b.model
+	opts.Price = b.price
+	if b.limits != (backend.Limits{}) {
+		opts.Model = backend.WithLimits(b.model, b.price, b.limits)
+	}
+	interp, err := interpreter.New(b.stdin, b.stdout, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Runtime{interp: interp, builtins: opts.Builtins}, nil
+}
+
+// WithStdin sets where input statements read from. Defaults to os.Stdin.
+func (b *RuntimeBuilder) WithStdin(r io.Reader) *RuntimeBuilder {
+	b.stdin = r
+	return b
+}
+
+// WithStdout sets where print statements write to. Defaults to os.Stdout.
+func (b *RuntimeBuilder) WithStdout(w io.Writer) *RuntimeBuilder {
+	b.stdout = w
+	return b
+}
+
+// WithStderr sets where eprint statements write to. Defaults to os.Stderr.
+func (b *RuntimeBuilder) WithStderr(w io.Writer) *RuntimeBuilder {
+	b.opts.Stderr = w
+	return b
+}
+
+// WithLimits stops the runtime calling the model once any of limits are reached, counting cost with price.
+func (b *RuntimeBuilder) WithLimits(price backend.Price, limits backend.Limits) *RuntimeBuilder {
+	b.price = price
+	b.limits = limits
+	return b
+}
+
+// WithTracer makes the runtime tell t about each statement it runs, and each model call, function call and file access they make.
+func (b *RuntimeBuilder) WithTracer(t interpreter.Tracer) *RuntimeBuilder {
+	b.opts.Tracer = t
+	return b
+}
+
+// WithDebugger makes the runtime ask d before each statement whether to pause the program.
+func (b *RuntimeBuilder) WithDebugger(d interpreter.Debugger) *RuntimeBuilder {
+	b.opts.Debugger = d
+	return b
+}
+
+// WithErrorScope makes runtime errors record the variables in scope where they happened, as well as the call stack.
+func (b *RuntimeBuilder) WithErrorScope() *RuntimeBuilder {
+	b.opts.ErrorScope = true
+	return b
+}
+
+// WithPrompts replaces the built-in prompts.
+func (b *RuntimeBuilder) WithPrompts(p *interpreter.Prompts) *RuntimeBuilder {
+	b.opts.Prompts = p
+	return b
+}
+
+// WithNarrow only sends the model the variables each statement references or lists with using.
+func (b *RuntimeBuilder) WithNarrow() *RuntimeBuilder {
+	b.opts.Narrow = true
+	return b
+}
+
+// WithOutput sets how print statements format their output.
+func (b *RuntimeBuilder) WithOutput(mode interpreter.OutputMode) *RuntimeBuilder {
+	b.opts.Output = mode
+	return b
+}
+
+// WithFSRoot lets programs read and write files inside root. If dryRun is set, writes are reported to stderr instead of made.
+func (b *RuntimeBuilder) WithFSRoot(root string, dryRun bool) *RuntimeBuilder {
+	b.opts.FSRoot = root
+	b.opts.DryRun = dryRun
+	return b
+}
+
+// Load parses and runs the program in src, with args as its command line arguments.
+// Imports are resolved relative to path, the file the program came from, which may be empty.
+// The program can use the variables and functions that programs loaded before it defined.
+func (r *Runtime) Load(path, src string, args ...string) error {
+	defined := []string{}
+	for name := range r.interp.Scope().KVPs() {
+		defined = append(defined, name)
+	}
+	code, err := parser.ParseSource(src, defined...)
+	if err != nil {
+		return err
+	}
+	return r.Run(path, code, args...)
+}
+
+// LoadFile reads, parses and runs the program in the file at path, with args as its command line arguments.
+func (r *Runtime) LoadFile(path string, args ...string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading source file '%s': %w", path, err)
+	}
+	return r.Load(path, string(src), args...)
+}
+
+// Run runs an already parsed program, with args as its command line arguments.
+// Imports are resolved relative to path, the file the program came from, which may be empty.
+func (r *Runtime) Run(path string, code []parser.ASTNode, args ...string) error {
+	return r.interp.Run(code, path, args)
+}
+
+// Test runs an already parsed program, then the body of its test block called name in a scope of its own.
+// A runtime should only be used for one test, so that tests cannot affect each other.
+func (r *Runtime) Test(path string, code []parser.ASTNode, name string) error {
+	return r.interp.Test(code, path, name)
+}
+
+// Call runs the function called name, which a loaded program defined or is a builtin, and returns the values it returns.
+func (r *Runtime) Call(name string, args ...string) ([]string, error) {
+	return r.interp.Call(name, args...)
+}
+
+// Scope returns the global scope that programs are run in, so that the variables they define can be read.
+func (r *Runtime) Scope() *interpreter.Scope {
+	return r.interp.Scope()
+}
+
+// RegisterBuiltin makes a Go function callable with run from every program and module the runtime runs.
+// It is called with one string per name in args, and must return one string per value it returns.
+func (r *Runtime) RegisterBuiltin(name string, args []string, fn interpreter.NativeFunc) {
+	r.builtins.Register(name, args, fn)
+}
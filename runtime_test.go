@@ -0,0 +1,65 @@
+package hellm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/JoshPattman/jpf"
+)
+
+// echoModel answers every question by repeating it.
+type echoModel struct{}
+
+func (echoModel) Tokens() (int, int) {
+	return 0, 0
+}
+
+func (echoModel) Respond(msgs []jpf.Message) ([]jpf.Message, jpf.Message, jpf.Usage, error) {
+	return nil, jpf.Message{Role: jpf.AssistantRole, Content: "echo: " + msgs[len(msgs)-1].Content}, jpf.Usage{}, nil
+}
+
+func TestRuntimeLoadThenLoad(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	rt, err := BuildRuntime(echoModel{}).WithStdout(stdout).Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.Load("", `
+const greeting = "Hello";
+import "std/text" as text;
+fn greet name {
+    let msg = "Say hello to {name}";
+    return msg;
+}
+`)
+	if err != nil {
+		t.Fatalf("loading the first program: %v", err)
+	}
+	err = rt.Load("", `
+run msg = greet greeting;
+print msg;
+run points = text.bullet_points msg;
+fn shout name {
+    run msg = greet name;
+    return msg;
+}
+`)
+	if err != nil {
+		t.Fatalf("loading a program using the first one's variables and functions: %v", err)
+	}
+	if !strings.HasPrefix(stdout.String(), "echo: ") {
+		t.Errorf("expected the second program to print the greeting, got %q", stdout.String())
+	}
+	outputs, err := rt.Call("shout", "Bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || !strings.Contains(outputs[0], "Say hello to Bob") {
+		t.Errorf("expected shout to return the greeting for Bob, got %q", outputs)
+	}
+
+	if err := rt.Load("", `print nobody;`); err == nil || !strings.Contains(err.Error(), "variable nobody is not in scope") {
+		t.Errorf("expected variables that were never defined to still be rejected, got %v", err)
+	}
+}
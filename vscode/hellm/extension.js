@@ -99,6 +99,37 @@ function activate(context) {
     console.log('Document formatting provider registered');
 
     context.subscriptions.push(documentFormattingProvider);
+
+    // Debugging is done by `hellm dap`, which speaks the Debug Adapter Protocol over stdin and stdout
+    const debugAdapterFactory = vscode.debug.registerDebugAdapterDescriptorFactory('hellm', {
+        createDebugAdapterDescriptor() {
+            const config = vscode.workspace.getConfiguration('hellm');
+            const hellmPath = config.get('hellmPath', 'hellm');
+            return new vscode.DebugAdapterExecutable(hellmPath, ['dap']);
+        }
+    });
+
+    // Lets F5 debug the open file without a launch.json
+    const debugConfigurationProvider = vscode.debug.registerDebugConfigurationProvider('hellm', {
+        resolveDebugConfiguration(folder, config) {
+            if (!config.type && !config.request && !config.name) {
+                const editor = vscode.window.activeTextEditor;
+                if (editor && editor.document.languageId === 'hellm') {
+                    config.type = 'hellm';
+                    config.request = 'launch';
+                    config.name = 'Debug HeLLM script';
+                    config.program = '${file}';
+                }
+            }
+            if (!config.program) {
+                vscode.window.showErrorMessage('Cannot find a HeLLM script to debug');
+                return undefined;
+            }
+            return config;
+        }
+    });
+
+    context.subscriptions.push(debugAdapterFactory, debugConfigurationProvider);
 }
 
 function deactivate() {}